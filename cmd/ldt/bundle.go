@@ -0,0 +1,382 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mdouchement/ldt/pkg/engine"
+	"github.com/mdouchement/ldt/pkg/primitive"
+	"github.com/spf13/cobra"
+)
+
+// A bundle is an ldt executable followed by a zip payload and a trailer:
+//
+//	executable | zip payload | payload size (uint64 LE) | bundleMagic
+//
+// The payload holds the entry action, its Lua modules and its data files.
+// Tengo actions are embedded compiled, so their imported modules are part of the bytecode.
+const bundleMagic = "LDTBUNDL"
+
+// A bundleManifest is stored as the comment of the zip payload.
+type bundleManifest struct {
+	Entry   string        `json:"entry"`
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+var (
+	// bundled is the content of the bundle when ldt runs as a bundle.
+	bundled fs.FS
+	data    []string
+
+	reLuaRequireAny = regexp.MustCompile(`require\s*\(?\s*["']([\w./-]+)["']`)
+)
+
+func bundle(_ *cobra.Command, args []string) error {
+	filename, err := resolve(args[0])
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+
+	var payload bytes.Buffer
+	zw := zip.NewWriter(&payload)
+	root := filepath.Dir(filename)
+
+	var manifest bundleManifest
+	if manifest.Timeout, err = fileTimeout(filename); err != nil {
+		return err
+	}
+
+	e := actionEngine(filename)
+	switch {
+	case filepath.Ext(filename) == ".tgoc":
+		manifest.Entry = filepath.Base(filename)
+		if err = addBundleFile(zw, filename, manifest.Entry); err != nil {
+			return err
+		}
+	case e == engine.Tengo:
+		code, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		bytecode, err := engine.Tengo.Bytecode(engine.Source{
+			Name:      filepath.Base(filename),
+			Code:      stripShebang(code),
+			ImportDir: root,
+		})
+		if err != nil {
+			return err
+		}
+
+		manifest.Entry = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + ".tgoc"
+		w, err := zw.Create(manifest.Entry)
+		if err != nil {
+			return err
+		}
+
+		if err = encodeBytecode(w, &bytecodeHeader{Version: ldtversion()}, bytecode); err != nil {
+			return err
+		}
+	case e == engine.Lua:
+		manifest.Entry = filepath.Base(filename)
+		if filepath.Ext(manifest.Entry) != ".lua" {
+			manifest.Entry += ".lua"
+		}
+		if err = addBundleFile(zw, filename, manifest.Entry); err != nil {
+			return err
+		}
+
+		if err = addLuaRequires(zw, root, filename, map[string]bool{}); err != nil {
+			return err
+		}
+	case e != nil:
+		return fmt.Errorf("unsupported language: %s", e.Name())
+	default:
+		return errors.New("unsupported action format")
+	}
+
+	for _, pattern := range data {
+		if err = addBundleData(zw, root, pattern); err != nil {
+			return err
+		}
+	}
+
+	comment, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err = zw.SetComment(string(comment)); err != nil {
+		return err
+	}
+
+	if err = zw.Close(); err != nil {
+		return err
+	}
+
+	return writeBundle(output, payload.Bytes())
+}
+
+// addLuaRequires adds the Lua modules required by the given file, and their own requires.
+// Modules are looked up relative to root like the default `./?.lua` package path.
+func addLuaRequires(zw *zip.Writer, root, filename string, seen map[string]bool) error {
+	code, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range reLuaRequireAny.FindAllStringSubmatch(string(code), -1) {
+		name := m[1]
+		if strings.HasPrefix(name, "lualib/") || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		base := strings.ReplaceAll(name, ".", "/")
+		for _, candidate := range []string{base + ".lua", base + "/init.lua"} {
+			module := filepath.Join(root, filepath.FromSlash(candidate))
+			if !primitive.Exist(module) {
+				continue
+			}
+
+			if err = addBundleFile(zw, module, candidate); err != nil {
+				return err
+			}
+			if err = addLuaRequires(zw, root, module, seen); err != nil {
+				return err
+			}
+			break
+		}
+		// Other modules are expected to be builtin.
+	}
+
+	return nil
+}
+
+// addBundleData adds the data files matching the given pattern, directories are added recursively.
+func addBundleData(zw *zip.Writer, root, pattern string) error {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("%s: no such data file", pattern)
+	}
+
+	for _, match := range matches {
+		err = filepath.WalkDir(match, func(filename string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			name, err := filepath.Rel(root, filename)
+			if err != nil {
+				return err
+			}
+			if name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+				return fmt.Errorf("%s: data files must be located under %s", filename, root)
+			}
+
+			return addBundleFile(zw, filename, filepath.ToSlash(name))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func addBundleFile(zw *zip.Writer, filename, name string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// writeBundle writes the running executable followed by the given payload.
+func writeBundle(filename string, payload []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(exe)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Bundling from a bundle, only the runtime is kept.
+	size, _, err := bundlePayload(src)
+	if err != nil {
+		return err
+	}
+
+	tmp := filename + ".part"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	if _, err = io.Copy(f, io.NewSectionReader(src, 0, size)); err != nil {
+		return err
+	}
+
+	if _, err = f.Write(payload); err != nil {
+		return err
+	}
+
+	trailer := binary.LittleEndian.AppendUint64(nil, uint64(len(payload)))
+	if _, err = f.Write(append(trailer, bundleMagic...)); err != nil {
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filename)
+}
+
+// bundlePayload returns the size of the executable part of the given file and its payload if any.
+func bundlePayload(f *os.File) (int64, []byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	size := info.Size()
+	trailer := make([]byte, 8+len(bundleMagic))
+	if size < int64(len(trailer)) {
+		return size, nil, nil
+	}
+
+	if _, err = f.ReadAt(trailer, size-int64(len(trailer))); err != nil {
+		return 0, nil, err
+	}
+	if string(trailer[8:]) != bundleMagic {
+		return size, nil, nil
+	}
+
+	n := int64(binary.LittleEndian.Uint64(trailer))
+	offset := size - int64(len(trailer)) - n
+	if n <= 0 || offset < 0 {
+		return 0, nil, errors.New("corrupted bundle")
+	}
+
+	payload := make([]byte, n)
+	if _, err = f.ReadAt(payload, offset); err != nil {
+		return 0, nil, err
+	}
+
+	return offset, payload, nil
+}
+
+// openBundle returns the content of the running executable's bundle, nil if it is not a bundle.
+func openBundle() (*zip.Reader, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, nil // Not a bundle as far as we can tell.
+	}
+
+	f, err := os.Open(exe)
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+
+	_, payload, err := bundlePayload(f)
+	if err != nil || payload == nil {
+		return nil, err
+	}
+
+	return zip.NewReader(bytes.NewReader(payload), int64(len(payload)))
+}
+
+// runBundle runs the entry action of the bundle, all the CLI arguments are forwarded to the action.
+func runBundle(zr *zip.Reader) (err error) {
+	var manifest bundleManifest
+	if err := json.Unmarshal([]byte(zr.Comment), &manifest); err != nil {
+		return fmt.Errorf("invalid bundle manifest: %w", err)
+	}
+
+	bundled = zr
+	engine.Lua.Preload = zr
+	primitive.SetAssets(zr)
+
+	primitive.StartReport()
+	defer summarize("")
+
+	journal, err := primitive.OpenJournal()
+	if err != nil {
+		return fmt.Errorf("could not open journal: %w", err)
+	}
+	defer journal.Close()
+
+	defer func() {
+		if herr := primitive.RunExitHooks(); herr != nil && err == nil {
+			err = fmt.Errorf("exit hook: %w", herr)
+		}
+	}()
+	defer watchInterrupt(manifest.Timeout)()
+
+	// Keep the same layout as `ldt action [args...]` for the scripts.
+	os.Args = append([]string{os.Args[0], manifest.Entry}, os.Args[1:]...)
+
+	switch path.Ext(manifest.Entry) {
+	case ".tgoc":
+		f, err := zr.Open(manifest.Entry)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, bytecode, err := decodeBytecode(f, engine.Tengo.Modules())
+		if err != nil {
+			return fmt.Errorf("%s: %w", manifest.Entry, err)
+		}
+
+		return engine.NewTengoProgram(bytecode).Run(primitive.Context(), os.Args[1:])
+	case ".lua":
+		code, err := fs.ReadFile(zr, manifest.Entry)
+		if err != nil {
+			return err
+		}
+
+		program, err := engine.Lua.Compile(engine.Source{Name: manifest.Entry, Code: stripShebang(code)})
+		if err != nil {
+			return err
+		}
+
+		return program.Run(primitive.Context(), os.Args[1:])
+	default:
+		return fmt.Errorf("unsupported bundle entry: %s", manifest.Entry)
+	}
+}
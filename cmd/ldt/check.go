@@ -0,0 +1,312 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/Shopify/go-lua"
+	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/parser"
+	"github.com/d5/tengo/v2/token"
+	"github.com/mdouchement/ldt/pkg/engine"
+	"github.com/mdouchement/ldt/pkg/tengolib"
+	"github.com/spf13/cobra"
+)
+
+// A problem is an issue found by the static validation of an action.
+type problem struct {
+	pos string // file:line:col
+	msg string
+}
+
+func check(_ *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		filenames, err := lookup("*")
+		if err != nil {
+			return fmt.Errorf("could not list actions: %w", err)
+		}
+		args = filenames
+	}
+
+	var n int
+	for _, action := range args {
+		filename, err := resolve(action)
+		if err != nil {
+			fmt.Printf("%s: %s\n", action, err)
+			n++
+			continue
+		}
+
+		var problems []problem
+		if filepath.Ext(filename) == ".tgoc" {
+			if _, _, err := readBytecode(filename, engine.Tengo.Modules()); err != nil {
+				problems = []problem{{pos: filename, msg: err.Error()}}
+			}
+		} else {
+			problems = checkAction(actionEngine(filename), filename)
+		}
+
+		for _, p := range problems {
+			fmt.Printf("%s: %s\n", p.pos, p.msg)
+		}
+		n += len(problems)
+	}
+
+	if n > 0 {
+		return fmt.Errorf("%d problem(s) found", n)
+	}
+	return nil
+}
+
+// checkAction validates the given action with the checker of its engine.
+// The languages without a dedicated checker are only compiled.
+func checkAction(e engine.Engine, filename string) []problem {
+	switch e {
+	case nil:
+		return []problem{{pos: filename, msg: "unsupported action format"}}
+	case engine.Lua:
+		return checklua(filename, e.Libraries())
+	case engine.Tengo:
+		return checktengo(filename)
+	}
+
+	code, err := os.ReadFile(filename)
+	if err != nil {
+		return []problem{{pos: filename, msg: err.Error()}}
+	}
+
+	_, err = e.Compile(engine.Source{
+		Name:      filename,
+		Code:      stripShebang(code),
+		ImportDir: filepath.Dir(filename),
+	})
+	if err != nil {
+		return []problem{{pos: filename, msg: err.Error()}}
+	}
+	return nil
+}
+
+//
+//
+// Lua
+//
+//
+
+var (
+	reLuaRequire = regexp.MustCompile(`require\s*\(?\s*["'](lualib/[\w/]+)["']`)
+	reLuaAlias   = regexp.MustCompile(`(?:local\s+)?([A-Za-z_]\w*)\s*=\s*require\s*\(?\s*["'](lualib/[\w/]+)["']`)
+	reLuaError   = regexp.MustCompile(`^(.+:\d+):\s*(.*)$`)
+)
+
+// checklua validates the given Lua file, the required lualib modules are looked up in the given libraries.
+func checklua(filename string, libraries map[string][]string) []problem {
+	l := lua.NewState()
+	if err := lua.LoadFile(l, filename, ""); err != nil {
+		msg, _ := l.ToString(-1)
+		if m := reLuaError.FindStringSubmatch(msg); m != nil {
+			return []problem{{pos: m[1], msg: m[2]}}
+		}
+		return []problem{{pos: filename, msg: msg}}
+	}
+
+	code, err := os.ReadFile(filename)
+	if err != nil {
+		return []problem{{pos: filename, msg: err.Error()}}
+	}
+
+	// There is no AST available with go-lua, so the lualib usages are checked line by line.
+	var problems []problem
+	aliases := map[string]map[string]bool{}
+	for i, line := range strings.Split(string(code), "\n") {
+		if comment := strings.Index(line, "--"); comment >= 0 {
+			line = line[:comment]
+		}
+
+		for _, m := range reLuaRequire.FindAllStringSubmatchIndex(line, -1) {
+			name := line[m[2]:m[3]]
+			if _, ok := libraries[name]; !ok {
+				problems = append(problems, problem{
+					pos: fmt.Sprintf("%s:%d:%d", filename, i+1, m[2]+1),
+					msg: fmt.Sprintf("unknown module: %s", name),
+				})
+			}
+		}
+
+		for _, m := range reLuaAlias.FindAllStringSubmatch(line, -1) {
+			if library, ok := libraries[m[2]]; ok {
+				members := map[string]bool{}
+				for _, name := range library {
+					members[name] = true
+				}
+				aliases[m[1]] = members
+			}
+		}
+
+		for alias, members := range aliases {
+			re := regexp.MustCompile(`(?:^|[^\w.])` + regexp.QuoteMeta(alias) + `\.([A-Za-z_]\w*)`)
+			for _, m := range re.FindAllStringSubmatchIndex(line, -1) {
+				member := line[m[2]:m[3]]
+				if !members[member] {
+					problems = append(problems, problem{
+						pos: fmt.Sprintf("%s:%d:%d", filename, i+1, m[2]+1),
+						msg: fmt.Sprintf("unknown module member: %s.%s", alias, member),
+					})
+				}
+			}
+		}
+	}
+
+	return problems
+}
+
+//
+//
+// Tengo
+//
+//
+
+func checktengo(filename string) []problem {
+	code, err := os.ReadFile(filename)
+	if err != nil {
+		return []problem{{pos: filename, msg: err.Error()}}
+	}
+
+	code = stripShebang(code)
+	modules := engine.Tengo.Modules()
+
+	fileset := parser.NewFileSet()
+	src := fileset.AddFile(filename, -1, len(code))
+
+	p := parser.NewParser(src, code, nil)
+	file, err := p.ParseFile()
+	if err != nil {
+		var list parser.ErrorList
+		if errors.As(err, &list) {
+			problems := make([]problem, 0, len(list))
+			for _, e := range list {
+				problems = append(problems, problem{pos: e.Pos.String(), msg: e.Msg})
+			}
+			return problems
+		}
+		return []problem{{pos: filename, msg: err.Error()}}
+	}
+
+	c := tengo.NewCompiler(src, tengolib.NewSymbolTable(), nil, modules, nil)
+	c.EnableFileImport(true)
+	c.SetImportDir(filepath.Dir(filename))
+
+	problems := checkTengoMembers(fileset, file, modules)
+
+	if err := c.Compile(file); err != nil {
+		var cerr *tengo.CompilerError
+		if errors.As(err, &cerr) {
+			return append(problems, problem{pos: cerr.FileSet.Position(cerr.Node.Pos()).String(), msg: cerr.Err.Error()})
+		}
+		return append(problems, problem{pos: filename, msg: err.Error()})
+	}
+
+	return problems
+}
+
+// checkTengoMembers reports selectors on imported builtin modules that do not exist.
+func checkTengoMembers(fileset *parser.SourceFileSet, file *parser.File, modules *tengo.ModuleMap) []problem {
+	// Identifiers only assigned with the import of the same builtin module.
+	imports := map[string]string{}
+	discarded := map[string]bool{}
+	walkTengo(file, func(node parser.Node) {
+		stmt, ok := node.(*parser.AssignStmt)
+		if !ok || len(stmt.LHS) != len(stmt.RHS) {
+			return
+		}
+
+		for i, lhs := range stmt.LHS {
+			ident, ok := lhs.(*parser.Ident)
+			if !ok {
+				continue
+			}
+
+			imp, ok := stmt.RHS[i].(*parser.ImportExpr)
+			if !ok || (imports[ident.Name] != "" && imports[ident.Name] != imp.ModuleName) || stmt.Token != token.Define && stmt.Token != token.Assign {
+				discarded[ident.Name] = true
+				continue
+			}
+			imports[ident.Name] = imp.ModuleName
+		}
+	})
+
+	var problems []problem
+	walkTengo(file, func(node parser.Node) {
+		selector, ok := node.(*parser.SelectorExpr)
+		if !ok {
+			return
+		}
+
+		ident, ok := selector.Expr.(*parser.Ident)
+		if !ok || discarded[ident.Name] || imports[ident.Name] == "" {
+			return
+		}
+
+		sel, ok := selector.Sel.(*parser.StringLit)
+		if !ok {
+			return
+		}
+
+		mod := modules.GetBuiltinModule(imports[ident.Name])
+		if mod == nil {
+			return // Source module
+		}
+
+		if _, ok := mod.Attrs[sel.Value]; !ok {
+			problems = append(problems, problem{
+				pos: fileset.Position(sel.Pos()).String(),
+				msg: fmt.Sprintf("unknown module member: %s.%s", imports[ident.Name], sel.Value),
+			})
+		}
+	})
+
+	return problems
+}
+
+var tengoNode = reflect.TypeOf((*parser.Node)(nil)).Elem()
+
+// walkTengo calls fn for each node of the AST.
+// The parser package does not provide any walker so the nodes are visited with reflection.
+func walkTengo(node parser.Node, fn func(parser.Node)) {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return
+	}
+	fn(node)
+
+	v := reflect.ValueOf(node).Elem()
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.Slice:
+			for j := 0; j < field.Len(); j++ {
+				walkTengoValue(field.Index(j), fn)
+			}
+		default:
+			walkTengoValue(field, fn)
+		}
+	}
+}
+
+func walkTengoValue(v reflect.Value, fn func(parser.Node)) {
+	if !v.CanInterface() || !v.Type().Implements(tengoNode) {
+		return
+	}
+	if (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) && v.IsNil() {
+		return
+	}
+
+	walkTengo(v.Interface().(parser.Node), fn)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/d5/tengo/v2"
+	"github.com/mdouchement/ldt/pkg/engine"
+	"github.com/mdouchement/ldt/pkg/primitive"
+	"github.com/spf13/cobra"
+)
+
+// A bytecodeHeader is written before the encoded bytecode of compiled Tengo scripts.
+type bytecodeHeader struct {
+	Version string            `json:"version"`
+	Imports map[string]string `json:"imports,omitempty"` // Imported files and their checksum
+}
+
+var (
+	nocache bool
+	output  string
+)
+
+func compile(_ *cobra.Command, args []string) error {
+	filename, err := resolve(args[0])
+	if err != nil {
+		return err
+	}
+
+	if actionEngine(filename) != engine.Tengo {
+		return errors.New("only Tengo actions can be compiled")
+	}
+
+	code, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	bytecode, err := engine.Tengo.Bytecode(engine.Source{
+		Name:      filepath.Base(filename),
+		Code:      stripShebang(code),
+		ImportDir: filepath.Dir(filename),
+	})
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		output = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".tgoc"
+	}
+
+	return writeBytecode(output, &bytecodeHeader{Version: ldtversion()}, bytecode)
+}
+
+func runtgoc(args []string) error {
+	_, bytecode, err := readBytecode(args[0], engine.Tengo.Modules())
+	if err != nil {
+		return err
+	}
+
+	return engine.NewTengoProgram(bytecode).Run(primitive.Context(), args)
+}
+
+// cachedtengo returns the compiled Tengo script from the cache or compiles it.
+// The cache entry is keyed by the ldt version and the script's location and content,
+// and is invalidated as soon as one of the imported files changes.
+func cachedtengo(filename string) (*tengo.Bytecode, error) {
+	code, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	src := engine.Source{
+		Name:      filename,
+		Code:      stripShebang(code),
+		ImportDir: filepath.Dir(filename),
+	}
+
+	dir, err := primitive.CacheDir()
+	if err != nil {
+		return engine.Tengo.Bytecode(src)
+	}
+
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00", ldtversion(), abs)
+	h.Write(code)
+	cache := filepath.Join(dir, "tengo", hex.EncodeToString(h.Sum(nil))+".tgoc")
+
+	header, bytecode, err := readBytecode(cache, engine.Tengo.Modules())
+	if err == nil && header.Version == ldtversion() && unchanged(header.Imports) {
+		return bytecode, nil
+	}
+
+	bytecode, err = engine.Tengo.Bytecode(src)
+	if err != nil {
+		return nil, err
+	}
+
+	header = &bytecodeHeader{
+		Version: ldtversion(),
+		Imports: map[string]string{},
+	}
+	for _, f := range bytecode.FileSet.Files {
+		// Imported files are registered with their absolute path by the compiler.
+		if !filepath.IsAbs(f.Name) {
+			continue
+		}
+
+		header.Imports[f.Name], err = filesum(f.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The cache is an optimization, failing to write it must not prevent the script from running.
+	_ = os.MkdirAll(filepath.Dir(cache), 0755)
+	_ = writeBytecode(cache, header, bytecode)
+
+	return bytecode, nil
+}
+
+// writeBytecode writes the JSON header on the first line followed by the encoded bytecode.
+func writeBytecode(filename string, header *bytecodeHeader, bytecode *tengo.Bytecode) error {
+	tmp := filename + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+
+	if err = encodeBytecode(f, header, bytecode); err != nil {
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filename)
+}
+
+func readBytecode(filename string, modules *tengo.ModuleMap) (*bytecodeHeader, *tengo.Bytecode, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	header, bytecode, err := decodeBytecode(f, modules)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return header, bytecode, nil
+}
+
+func encodeBytecode(w io.Writer, header *bytecodeHeader, bytecode *tengo.Bytecode) error {
+	payload, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(append(payload, '\n')); err != nil {
+		return err
+	}
+
+	return bytecode.Encode(w)
+}
+
+func decodeBytecode(r io.Reader, modules *tengo.ModuleMap) (*bytecodeHeader, *tengo.Bytecode, error) {
+	br := bufio.NewReader(r)
+	payload, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var header bytecodeHeader
+	if err = json.Unmarshal(payload, &header); err != nil {
+		return nil, nil, fmt.Errorf("invalid header: %w", err)
+	}
+
+	bytecode := new(tengo.Bytecode)
+	if err = bytecode.Decode(br, modules); err != nil {
+		return nil, nil, err
+	}
+
+	return &header, bytecode, nil
+}
+
+func unchanged(imports map[string]string) bool {
+	for filename, sum := range imports {
+		current, err := filesum(filename)
+		if err != nil || current != sum {
+			return false
+		}
+	}
+
+	return true
+}
+
+func filesum(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hashes, err := primitive.Checksum(f, primitive.ChecksumSHA256)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hashes[primitive.ChecksumSHA256].Sum(nil)), nil
+}
+
+func ldtversion() string {
+	return fmt.Sprintf("%s-%s-%s", version, revision, date)
+}
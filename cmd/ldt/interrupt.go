@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+var (
+	timeout time.Duration
+
+	// e.g. `-- ldt:timeout 5m` or `// ldt:timeout=30s`
+	reTimeout = regexp.MustCompile(`^(?:--|//)\s*ldt:timeout[\s=:]+(\S+)`)
+)
+
+// actionTimeout returns the timeout declared in the leading comments of the given code, 0 if none.
+func actionTimeout(code []byte) (time.Duration, error) {
+	s := bufio.NewScanner(bytes.NewReader(stripShebang(code)))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "//") {
+			break
+		}
+
+		if m := reTimeout.FindStringSubmatch(line); m != nil {
+			d, err := time.ParseDuration(m[1])
+			if err != nil {
+				return 0, fmt.Errorf("invalid timeout metadata: %w", err)
+			}
+			return d, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// fileTimeout returns the timeout of the given action, the --timeout flag takes precedence over the action's metadata.
+func fileTimeout(filename string) (time.Duration, error) {
+	if timeout > 0 || filename == "" {
+		return timeout, nil
+	}
+
+	code, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+
+	return actionTimeout(code)
+}
+
+// watchInterrupt interrupts the run on SIGINT/SIGTERM or once the given timeout (if any) is exceeded.
+// A second signal kills ldt right away.
+func watchInterrupt(d time.Duration) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	var timer *time.Timer
+	if d > 0 {
+		timer = time.AfterFunc(d, func() {
+			primitive.Interrupt(&primitive.TimeoutError{Timeout: d})
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			primitive.Interrupt(fmt.Errorf("interrupted by %s", sig))
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
@@ -2,18 +2,13 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
-	"strings"
-
-	"github.com/Shopify/go-lua"
-	"github.com/Shopify/goluago"
-	"github.com/d5/tengo/v2"
-	"github.com/d5/tengo/v2/parser"
-	"github.com/d5/tengo/v2/stdlib"
-	"github.com/mdouchement/ldt/pkg/lualib"
-	"github.com/mdouchement/ldt/pkg/tengolib"
+
+	"github.com/mdouchement/ldt/pkg/engine"
+	"github.com/mdouchement/ldt/pkg/primitive"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
@@ -27,116 +22,322 @@ var (
 	revision = "none"
 	date     = "unknown"
 
-	list        bool
-	extensions  = []string{".tgo", ".tengo", ".lua"} // in precedence order
-	mextensions = map[string]func([]string) error{
-		".lua":   runlua,
-		".tengo": runtengo,
-		".tgo":   runtengo,
-	}
+	list   bool
+	dryrun bool
+	eval   string
+	lang   string
+
+	sudoNonInteractive bool
+	yes                bool
+	logLevel           string
+	logFormat          string
+	reportFile         string
 )
 
 func main() {
+	if zr, err := openBundle(); err != nil || zr != nil {
+		if err == nil {
+			err = runBundle(zr)
+		}
+
+		if err != nil {
+			exit(err)
+		}
+		return
+	}
+
 	c := &cobra.Command{
 		Use:     appname,
 		Short:   "Lua dotfiles tool",
 		Version: fmt.Sprintf("%s - build %.7s @ %s - %s", version, revision, date, runtime.Version()),
+		Args:    cobra.ArbitraryArgs,
 		RunE:    action,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			return primitive.SetLogger(logLevel, logFormat)
+		},
 	}
 	c.Flags().BoolVarP(&list, "list", "l", false, "List the actions")
+	c.Flags().BoolVarP(&dryrun, "dry-run", "n", false, "Log side-effecting operations instead of performing them")
+	c.Flags().StringVarP(&eval, "eval", "e", "", "Run the given code instead of an action")
+	c.Flags().BoolVar(&nocache, "no-cache", false, "Do not use the compiled Tengo scripts cache")
+	c.Flags().BoolVarP(&yes, "yes", "y", false, "Accept all the confirmations (prompt.confirm) without asking")
+	c.Flags().BoolVar(&sudoNonInteractive, "sudo-non-interactive", false, "Never ask the sudo/doas password, privileged operations fail if it is required (e.g. in CI)")
+	c.Flags().StringVar(&reportFile, "report", "", "Export the summary of the run's operations as JSON to the given file (- for stdout)")
+	c.Flags().DurationVar(&timeout, "timeout", 0, "Abort the action after the given duration (overrides the action's ldt:timeout metadata)")
+	c.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Minimum level of the logs written on stderr (debug|info|warn|error)")
+	c.PersistentFlags().StringVar(&logFormat, "log-format", primitive.LogFormatText, "Format of the logs (text|json)")
+	c.PersistentFlags().StringVar(&lang, "lang", "lua", "Language of the code given by --eval, stdin, the REPL or an action without extension (lua|tengo)")
+
+	c.AddCommand(&cobra.Command{
+		Use:   "rollback [run-id]",
+		Short: "Restore the state prior to the given run (list the runs if no run-id is given)",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  rollback,
+	})
+
+	crepl := &cobra.Command{
+		Use:   "repl",
+		Short: "Start an interactive session with ldt libraries preloaded",
+		Args:  cobra.NoArgs,
+		RunE:  repl,
+	}
+	c.AddCommand(crepl)
+
+	c.AddCommand(&cobra.Command{
+		Use:           "check [actions...]",
+		Short:         "Parse and compile the actions without running them (all the actions if none is given)",
+		RunE:          check,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	})
+
+	ccompile := &cobra.Command{
+		Use:   "compile action",
+		Short: "Compile a Tengo action to a standalone .tgoc file",
+		Args:  cobra.ExactArgs(1),
+		RunE:  compile,
+	}
+	ccompile.Flags().StringVarP(&output, "output", "o", "", "Output file (default to the action name with .tgoc extension)")
+	c.AddCommand(ccompile)
+
+	cbundle := &cobra.Command{
+		Use:   "bundle action",
+		Short: "Build a self-contained executable running the given action",
+		Args:  cobra.ExactArgs(1),
+		RunE:  bundle,
+	}
+	cbundle.Flags().StringVarP(&output, "output", "o", "", "Output file (default to the action name)")
+	cbundle.Flags().StringArrayVarP(&data, "data", "d", nil, "Data files or directories to embed, readable with os.read_asset (glob patterns allowed)")
+	c.AddCommand(cbundle)
 
 	if err := c.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		exit(err)
+	}
+}
+
+// exit exits with the code requested by the script (e.g. ldt.halt) or logs the error.
+func exit(err error) {
+	var exiterr *primitive.ExitError
+	if errors.As(err, &exiterr) {
+		os.Exit(exiterr.Code)
+	}
+
+	msg := err.Error()
+
+	var serr *engine.Error
+	if errors.As(err, &serr) {
+		if primitive.LogFormat() == primitive.LogFormatText {
+			report(os.Stderr, serr)
+			os.Exit(1)
+		}
+		msg = serr.Message // The stack is given as a field
 	}
+
+	primitive.Logger().Error(msg, errorFields(serr)...)
+	os.Exit(1)
 }
 
-func action(_ *cobra.Command, args []string) error {
-	if list || len(args) == 0 {
+func action(cmd *cobra.Command, args []string) (err error) {
+	evaluated := cmd.Flags().Changed("eval")
+	inline := evaluated || len(args) > 0 && args[0] == "-"
+	if list || len(args) == 0 && !inline {
 		return listActions()
 	}
 
+	// From now on, errors come from the action so the usage is irrelevant.
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+
+	primitive.SetDryRun(dryrun)
+	primitive.SetSudoNonInteractive(sudoNonInteractive)
+	primitive.SetAssumeYes(yes)
+
+	// The summary comes last, after the operations of the exit hooks.
+	primitive.StartReport()
+	defer func() {
+		if serr := summarize(reportFile); serr != nil && err == nil {
+			err = errors.Wrap(serr, "could not export report")
+		}
+	}()
+
+	if !dryrun {
+		journal, err := primitive.OpenJournal()
+		if err != nil {
+			return errors.Wrap(err, "could not open journal")
+		}
+		defer journal.Close()
+	}
+
+	defer func() {
+		if herr := primitive.RunExitHooks(); herr != nil && err == nil {
+			err = errors.Wrap(herr, "exit hook")
+		}
+	}()
+
+	if inline {
+		defer watchInterrupt(timeout)()
+
+		primitive.SetAssets(os.DirFS("."))
+		return runInline(args, !evaluated)
+	}
+
+	filename, err := resolve(args[0])
+	if err != nil {
+		return err
+	}
+	primitive.SetAssets(os.DirFS(filepath.Dir(filename)))
+
+	d, err := fileTimeout(filename)
+	if err != nil {
+		return err
+	}
+	defer watchInterrupt(d)()
+
+	if filename != args[0] {
+		args[0] = filename
+		primitive.Logger().Info("Using action", "file", filename)
+	}
+
+	return runner(filename)(args)
+}
+
+// resolve returns the filename of the given action.
+func resolve(action string) (string, error) {
 	// The extension is already in the action.
-	if run, ok := mextensions[filepath.Ext(args[0])]; ok {
-		return run(args)
+	if ext := filepath.Ext(action); ext == ".tgoc" || engine.ByExtension(ext) != nil {
+		return action, nil
 	}
 
 	// The action does not have any extension.
-	filenames, err := lookup(args[0])
+	filenames, err := lookup(action)
 	if err != nil {
-		return errors.Wrap(err, "could not lookup action")
+		return "", errors.Wrap(err, "could not lookup action")
 	}
-	if len(filenames) == 0 {
-		return errors.New("not found")
+	if len(filenames) > 0 {
+		return filenames[0], nil
 	}
-	args[0] = filenames[0]
-
-	fmt.Println("Using", filenames[0])
 
-	if run, ok := mextensions[filepath.Ext(args[0])]; ok {
-		return run(args)
+	// An executable action without extension.
+	if runner(action) != nil {
+		return action, nil
 	}
 
-	return errors.New("unsupported action format")
+	if primitive.Exist(action) {
+		return "", errors.New("unsupported action format")
+	}
+	return "", errors.New("not found")
 }
 
-func runlua(args []string) error {
-	// Initialize Lua's VM and add defaults libraries
-	state := lua.NewState()
-	lua.OpenLibraries(state)
-	goluago.Open(state)
-	lualib.Open(state)
-
-	// Forward CLI args to Lua script
-	var argv = []string{}
-	if len(args) > 1 {
-		for _, arg := range args[1:] {
-			argv = append(argv, fmt.Sprintf(`"%s"`, arg)) // Surrounding `arg` with double quotes
+// runner returns the function that runs the given action according to its extension or its shebang.
+func runner(filename string) func([]string) error {
+	if filepath.Ext(filename) == ".tgoc" {
+		return runtgoc
+	}
+
+	e := actionEngine(filename)
+	if e == nil {
+		return nil
+	}
+
+	return func(args []string) error {
+		program, err := compileAction(e, args[0])
+		if err != nil {
+			return err
 		}
+
+		return program.Run(primitive.Context(), args)
 	}
-	stmt := fmt.Sprintf("arg = {%s}", strings.Join(argv, ", ")) // Create `arg` table
-	if err := lua.DoString(state, stmt); err != nil {
-		return errors.Wrap(err, "could not forward argv")
+}
+
+// actionEngine returns the engine of the given action file according to its extension or its shebang, nil if none.
+func actionEngine(filename string) engine.Engine {
+	if e := engine.ByExtension(filepath.Ext(filename)); e != nil {
+		return e
 	}
 
-	// Run the script
-	return errors.Wrap(lua.DoFile(state, args[0]), "could not run action")
+	l, ok := shebangLang(filename)
+	if !ok {
+		return nil
+	}
+	if l == "" {
+		l = lang
+	}
+	return engine.ByName(l)
 }
 
-func runtengo(args []string) error {
-	// Load modules
-	modules := stdlib.GetModuleMap(stdlib.AllModuleNames()...)
-	tengolib.MergeModule(modules, tengolib.AllModuleNames()...)
+// compileAction compiles the given action file, Tengo actions are cached unless --no-cache is given.
+func compileAction(e engine.Engine, filename string) (engine.Program, error) {
+	if e == engine.Tengo && !nocache {
+		bytecode, err := cachedtengo(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		return engine.NewTengoProgram(bytecode), nil
+	}
 
-	// Compile source code
-	code, err := os.ReadFile(args[0])
+	code, err := os.ReadFile(filename)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fileset := parser.NewFileSet()
-	src := fileset.AddFile(filepath.Base(args[0]), -1, len(code))
+	return e.Compile(engine.Source{
+		Name:      filename,
+		Code:      stripShebang(code),
+		ImportDir: filepath.Dir(filename),
+	})
+}
 
-	p := parser.NewParser(src, code, nil)
-	file, err := p.ParseFile()
-	if err != nil {
-		return err
+// runInline runs the code given by --eval or, if stdin is true, read from stdin (`ldt - [args...]`).
+func runInline(args []string, stdin bool) error {
+	name := "eval"
+	code := []byte(eval)
+	if stdin {
+		var err error
+		code, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			return errors.Wrap(err, "could not read stdin")
+		}
+
+		name = "stdin"
+		args = args[1:]
 	}
+	sources[name] = code
 
-	c := tengo.NewCompiler(src, nil, nil, modules, nil)
-	c.EnableFileImport(true)
-	c.SetImportDir(filepath.Dir(args[0]))
+	e := engine.ByName(lang)
+	if e == nil {
+		return fmt.Errorf("unsupported language: %s", lang)
+	}
 
-	if err := c.Compile(file); err != nil {
+	program, err := e.Compile(engine.Source{
+		Name:      name,
+		Code:      stripShebang(code),
+		ImportDir: ".",
+	})
+	if err != nil {
 		return err
 	}
 
-	bytecode := c.Bytecode()
-	bytecode.RemoveDuplicates()
+	return program.Run(primitive.Context(), append([]string{name}, args...))
+}
+
+func rollback(_ *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		ids, err := primitive.ListRuns()
+		if err != nil {
+			return errors.Wrap(err, "could not list runs")
+		}
+
+		fmt.Println("Available runs")
+		fmt.Println("==============")
+		for _, id := range ids {
+			fmt.Printf("  %s rollback %s\n", appname, id)
+		}
+
+		return nil
+	}
 
-	// Run the script
-	vm := tengo.NewVM(bytecode, nil, -1)
-	return vm.Run()
+	return errors.Wrap(primitive.Rollback(args[0]), "could not rollback")
 }
 
 func listActions() error {
@@ -166,7 +367,7 @@ func listActions() error {
 
 func lookup(basename string) ([]string, error) {
 	var filenames []string
-	for _, ext := range extensions {
+	for _, ext := range append(engine.Extensions(), ".tgoc") {
 		files, err := filepath.Glob(basename + ext)
 		if err != nil {
 			return nil, err
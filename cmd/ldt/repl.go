@@ -0,0 +1,438 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Shopify/go-lua"
+	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/parser"
+	"github.com/d5/tengo/v2/stdlib"
+	"github.com/mdouchement/ldt/pkg/engine"
+	"github.com/mdouchement/ldt/pkg/lualib"
+	"github.com/mdouchement/ldt/pkg/primitive"
+	"github.com/mdouchement/ldt/pkg/tengolib"
+	"github.com/peterh/liner"
+	"github.com/spf13/cobra"
+)
+
+const (
+	replPrompt             = ">> "
+	replContinuationPrompt = ".. "
+)
+
+// An evaluator evaluates REPL inputs with a persistent state.
+type evaluator interface {
+	// Eval evaluates the given code. It returns errIncomplete when more input is needed.
+	Eval(code string) error
+	// Complete returns the candidates for the given word.
+	Complete(word string) []string
+}
+
+var errIncomplete = errors.New("incomplete input")
+
+func repl(_ *cobra.Command, _ []string) error {
+	var eval evaluator
+	switch lang {
+	case "lua":
+		eval = newLuaEvaluator()
+	case "tengo", "tgo":
+		eval = newTengoEvaluator()
+	default:
+		return fmt.Errorf("unsupported language: %s", lang)
+	}
+
+	line := liner.NewLiner()
+	defer line.Close()
+	defer primitive.RunExitHooks()
+
+	line.SetCtrlCAborts(true)
+	line.SetWordCompleter(func(input string, pos int) (string, []string, string) {
+		head := input[:pos]
+		start := strings.LastIndexFunc(head, func(r rune) bool {
+			return !(r == '_' || r == '.' || r == '/' || isAlphanumeric(r))
+		}) + 1
+
+		return head[:start], eval.Complete(head[start:]), input[pos:]
+	})
+
+	history := ""
+	if dir, err := primitive.StateDir(); err == nil {
+		history = filepath.Join(dir, "repl_history")
+		if f, err := os.Open(history); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+
+	fmt.Printf("%s REPL (%s) - Ctrl-D to exit\n", appname, lang)
+
+	var code string
+	prompt := replPrompt
+	for {
+		input, err := line.Prompt(prompt)
+		if err == liner.ErrPromptAborted {
+			code = ""
+			prompt = replPrompt
+			continue
+		}
+		if err == io.EOF {
+			fmt.Println()
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		code += input + "\n"
+		if strings.TrimSpace(code) == "" {
+			code = ""
+			continue
+		}
+
+		err = eval.Eval(code)
+		if err == errIncomplete {
+			prompt = replContinuationPrompt
+			continue
+		}
+		var exiterr *primitive.ExitError
+		if errors.As(err, &exiterr) {
+			return err // ldt.halt
+		}
+		if err != nil {
+			fmt.Println(err)
+		}
+
+		line.AppendHistory(strings.TrimSpace(code))
+		code = ""
+		prompt = replPrompt
+	}
+
+	if history == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(history), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.Create(history)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = line.WriteHistory(f)
+	return err
+}
+
+//
+//
+// Lua
+//
+//
+
+type luaEvaluator struct {
+	state *lua.State
+}
+
+func newLuaEvaluator() *luaEvaluator {
+	state := engine.Lua.NewState()
+	if err := lua.DoString(state, "arg = {}"); err != nil {
+		panic(err)
+	}
+
+	return &luaEvaluator{
+		state: state,
+	}
+}
+
+func (e *luaEvaluator) Eval(code string) error {
+	l := e.state
+	top := l.Top()
+
+	// Each input is a chunk so a top-level local would not persist between inputs.
+	code = strings.TrimSpace(code)
+	code = strings.TrimPrefix(code, "local ")
+
+	// Try as an expression first in order to print its value.
+	if err := lua.LoadString(l, "return "+code); err != nil {
+		l.SetTop(top)
+
+		if err := lua.LoadString(l, code); err != nil {
+			msg, _ := l.ToString(-1)
+			l.SetTop(top)
+			if strings.HasSuffix(msg, "<eof>") {
+				return errIncomplete
+			}
+			return fmt.Errorf("%s: %s", err, msg)
+		}
+	}
+
+	if err := l.ProtectedCall(0, lua.MultipleReturns, 0); err != nil {
+		l.SetTop(top)
+		return err
+	}
+
+	var values []string
+	for i := top + 1; i <= l.Top(); i++ {
+		s, _ := lua.ToStringMeta(l, i)
+		values = append(values, s)
+		l.Pop(1) // ToStringMeta pushes the string onto the stack
+	}
+	l.SetTop(top)
+
+	if len(values) > 0 {
+		fmt.Println(strings.Join(values, "\t"))
+	}
+	return nil
+}
+
+func (e *luaEvaluator) Complete(word string) []string {
+	var candidates []string
+
+	i := strings.LastIndexByte(word, '.')
+	if i < 0 {
+		// Globals and libraries
+		l := e.state
+		l.PushGlobalTable()
+		candidates = append(candidates, tableKeys(l, "")...)
+		l.Pop(1)
+
+		for name := range lualib.Libraries {
+			candidates = append(candidates, fmt.Sprintf("require %q", name))
+		}
+
+		return filterPrefix(candidates, word)
+	}
+
+	prefix, member := word[:i], word[i+1:]
+
+	// Members of the registered libraries, e.g. `os.` for "lualib/os".
+	for name, library := range lualib.Libraries {
+		if filepath.Base(name) != prefix {
+			continue
+		}
+
+		for _, fn := range library {
+			candidates = append(candidates, prefix+"."+fn.Name)
+		}
+	}
+
+	// Members of a global table.
+	l := e.state
+	l.PushGlobalTable()
+	for _, field := range strings.Split(prefix, ".") {
+		if !l.IsTable(-1) {
+			break
+		}
+		l.Field(-1, field)
+		l.Remove(-2)
+	}
+	if l.IsTable(-1) {
+		candidates = append(candidates, tableKeys(l, prefix+".")...)
+	}
+	l.Pop(1)
+
+	return filterPrefix(uniq(candidates), prefix+"."+member)
+}
+
+func tableKeys(l *lua.State, prefix string) []string {
+	var keys []string
+
+	l.PushNil()
+	for l.Next(-2) {
+		if key, ok := l.ToString(-2); ok && l.TypeOf(-2) == lua.TypeString {
+			keys = append(keys, prefix+key)
+		}
+		l.Pop(1)
+	}
+
+	return keys
+}
+
+//
+//
+// Tengo
+//
+//
+
+type tengoEvaluator struct {
+	modules     *tengo.ModuleMap
+	fileset     *parser.SourceFileSet
+	symbols     *tengo.SymbolTable
+	globals     []tengo.Object
+	constants   []tengo.Object
+	modulenames []string
+}
+
+func newTengoEvaluator() *tengoEvaluator {
+	e := &tengoEvaluator{
+		modules: engine.Tengo.Modules(),
+		fileset: parser.NewFileSet(),
+		symbols: tengolib.NewSymbolTable(),
+		globals: make([]tengo.Object, tengo.GlobalsSize),
+	}
+
+	for idx, fn := range tengo.GetAllBuiltinFunctions() {
+		e.symbols.DefineBuiltin(idx, fn.Name)
+	}
+
+	symbol := e.symbols.Define("__repl_println__")
+	e.globals[symbol.Index] = &tengo.UserFunction{
+		Name: "println",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			for _, arg := range args {
+				if arg == tengo.UndefinedValue {
+					continue
+				}
+				fmt.Println(arg)
+			}
+			return tengo.UndefinedValue, nil
+		},
+	}
+
+	for _, name := range tengoModuleNames() {
+		if e.modules.Get(name) != nil {
+			e.modulenames = append(e.modulenames, name)
+		}
+	}
+
+	return e
+}
+
+func (e *tengoEvaluator) Eval(code string) error {
+	src := e.fileset.AddFile("repl", -1, len(code))
+	p := parser.NewParser(src, []byte(code), nil)
+	file, err := p.ParseFile()
+	if err != nil {
+		if isTengoIncomplete(err) {
+			return errIncomplete
+		}
+		return err
+	}
+
+	c := tengo.NewCompiler(src, e.symbols, e.constants, e.modules, nil)
+	c.EnableFileImport(true)
+	c.SetImportDir(".")
+
+	if err := c.Compile(replPrints(file)); err != nil {
+		return err
+	}
+
+	bytecode := c.Bytecode()
+	tengolib.SetRuntime(bytecode, e.globals)
+	vm := tengo.NewVM(bytecode, e.globals, -1)
+	if err := vm.Run(); err != nil {
+		return err
+	}
+
+	e.constants = bytecode.Constants
+	return nil
+}
+
+func (e *tengoEvaluator) Complete(word string) []string {
+	var candidates []string
+
+	i := strings.LastIndexByte(word, '.')
+	if i < 0 {
+		candidates = append(candidates, e.symbols.Names()...)
+		for _, name := range e.modulenames {
+			candidates = append(candidates, fmt.Sprintf("import(%q)", name))
+		}
+
+		return filterPrefix(uniq(candidates), word)
+	}
+
+	prefix, member := word[:i], word[i+1:]
+
+	// Members of a global variable holding a map, e.g. an imported module.
+	if symbol, _, ok := e.symbols.Resolve(prefix, false); ok && symbol.Scope == tengo.ScopeGlobal {
+		for key := range tengolib.MapValue(e.globals[symbol.Index]) {
+			candidates = append(candidates, prefix+"."+key)
+		}
+	}
+
+	// Members of the module named as the prefix.
+	if mod, ok := e.modules.Get(prefix).(*tengo.BuiltinModule); ok {
+		for key := range mod.Attrs {
+			candidates = append(candidates, prefix+"."+key)
+		}
+	}
+
+	return filterPrefix(uniq(candidates), prefix+"."+member)
+}
+
+func tengoModuleNames() []string {
+	return uniq(append(stdlib.AllModuleNames(), tengolib.AllModuleNames()...))
+}
+
+// isTengoIncomplete returns true when the parsing error is due to the end of the input.
+func isTengoIncomplete(err error) bool {
+	var list parser.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		return false
+	}
+
+	return strings.Contains(list[len(list)-1].Msg, "found 'EOF'")
+}
+
+// replPrints wraps expression statements in order to print their values.
+func replPrints(file *parser.File) *parser.File {
+	stmts := make([]parser.Stmt, 0, len(file.Stmts))
+	for _, stmt := range file.Stmts {
+		if expr, ok := stmt.(*parser.ExprStmt); ok {
+			stmt = &parser.ExprStmt{
+				Expr: &parser.CallExpr{
+					Func: &parser.Ident{Name: "__repl_println__"},
+					Args: []parser.Expr{expr.Expr},
+				},
+			}
+		}
+		stmts = append(stmts, stmt)
+	}
+
+	return &parser.File{
+		InputFile: file.InputFile,
+		Stmts:     stmts,
+	}
+}
+
+//
+//
+// Helpers
+//
+//
+
+func filterPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+
+	sort.Strings(matches)
+	return matches
+}
+
+func uniq(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var result []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+func isAlphanumeric(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
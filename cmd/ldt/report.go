@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mdouchement/ldt/pkg/engine"
+	"golang.org/x/term"
+)
+
+// sources holds the code of the scripts not read from a file (e.g. --eval).
+var sources = map[string][]byte{}
+
+func readSource(file string) ([]byte, error) {
+	if code, ok := sources[file]; ok {
+		return code, nil
+	}
+
+	if bundled != nil {
+		if code, err := fs.ReadFile(bundled, file); err == nil {
+			return code, nil
+		}
+	}
+
+	return os.ReadFile(file)
+}
+
+const (
+	ansiReset = "\033[0m"
+	ansiBold  = "\033[1m"
+	ansiDim   = "\033[2m"
+	ansiRed   = "\033[31m"
+	ansiBlue  = "\033[34m"
+)
+
+// report writes the failure report of the given error, colourised if w is a terminal.
+func report(w io.Writer, e *engine.Error) {
+	color := func(code, s string) string { return s }
+	if f, ok := w.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		color = func(code, s string) string { return code + s + ansiReset }
+	}
+
+	fmt.Fprintf(w, "%s %s\n", color(ansiBold+ansiRed, "error:"), color(ansiBold, e.Message))
+
+	if len(e.Frames) > 0 {
+		f := e.Frames[0]
+		fmt.Fprintf(w, " %s %s\n", color(ansiBlue, "-->"), f)
+
+		if code, err := readSource(f.File); err == nil {
+			snippet(w, color, strings.Split(string(code), "\n"), f)
+		}
+	}
+
+	if len(e.Frames) > 1 {
+		fmt.Fprintln(w, color(ansiDim, "stack traceback:"))
+		for _, f := range e.Frames {
+			fmt.Fprintln(w, color(ansiDim, "  "+f.String()))
+		}
+	}
+
+	for _, cause := range e.Causes {
+		fmt.Fprintf(w, "%s %s\n", color(ansiBold, "caused by:"), cause)
+	}
+}
+
+// snippet writes the source lines around the given frame with a caret under the offending location.
+func snippet(w io.Writer, color func(string, string) string, lines []string, f engine.Frame) {
+	if f.Line < 1 || f.Line > len(lines) {
+		return
+	}
+
+	first, last := max(f.Line-2, 1), min(f.Line+1, len(lines))
+	width := len(strconv.Itoa(last))
+	gutter := func(n string) string {
+		return color(ansiBlue, fmt.Sprintf("%*s |", width, n))
+	}
+
+	fmt.Fprintln(w, gutter(""))
+	for n := first; n <= last; n++ {
+		line := strings.TrimRight(lines[n-1], "\r")
+		fmt.Fprintf(w, "%s %s\n", gutter(strconv.Itoa(n)), line)
+
+		if n == f.Line {
+			col := f.Column
+			if col < 1 {
+				col = len(line) - len(strings.TrimLeft(line, " \t")) + 1
+			}
+
+			// Keep the tabs so the caret is aligned with the line above.
+			indent := strings.Map(func(r rune) rune {
+				if r == '\t' {
+					return r
+				}
+				return ' '
+			}, line[:min(col-1, len(line))])
+			fmt.Fprintf(w, "%s %s%s\n", gutter(""), indent, color(ansiBold+ansiRed, "^"))
+		}
+	}
+}
+
+// errorFields returns the location, stack and causes of the given script error as log fields.
+func errorFields(e *engine.Error) []any {
+	if e == nil {
+		return nil
+	}
+
+	var fields []any
+	if len(e.Frames) > 0 {
+		fields = append(fields, "file", e.Frames[0].File, "line", e.Frames[0].Line)
+
+		stack := make([]string, 0, len(e.Frames))
+		for _, f := range e.Frames {
+			stack = append(stack, f.String())
+		}
+		fields = append(fields, "stack", stack)
+	}
+
+	if len(e.Causes) > 0 {
+		fields = append(fields, "causes", e.Causes)
+	}
+
+	return fields
+}
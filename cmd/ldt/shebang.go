@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mdouchement/ldt/pkg/engine"
+)
+
+// stripShebang blanks the shebang line of the given code.
+// The line break is kept so the line numbers reported by the parsers stay accurate.
+func stripShebang(code []byte) []byte {
+	if !bytes.HasPrefix(code, []byte("#!")) {
+		return code
+	}
+
+	if i := bytes.IndexByte(code, '\n'); i >= 0 {
+		return code[i:]
+	}
+	return nil
+}
+
+// shebangLang returns the language hinted by the shebang of the given file.
+// e.g. `#!ldt lua`, `#!/usr/bin/env -S ldt --lang tengo`
+// It returns false if the file has no shebang or if its interpreter is not ldt (e.g. `#!/bin/sh`).
+func shebangLang(filename string) (string, bool) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	if !strings.HasPrefix(line, "#!") {
+		return "", false
+	}
+
+	args, ok := shebangArgs(strings.Fields(strings.TrimPrefix(line, "#!")))
+	if !ok {
+		return "", false
+	}
+
+	for i, arg := range args {
+		arg = strings.TrimPrefix(arg, "--lang=")
+		if arg == "--lang" && i+1 < len(args) {
+			arg = args[i+1]
+		}
+
+		if engine.ByName(arg) != nil {
+			return arg, true
+		}
+	}
+
+	// An ldt shebang without hint.
+	return "", true
+}
+
+// shebangArgs returns the arguments given to ldt by the given shebang fields,
+// false if the interpreter is not ldt, directly or through env.
+func shebangArgs(fields []string) ([]string, bool) {
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	if filepath.Base(fields[0]) == "env" {
+		fields = fields[1:]
+		for len(fields) > 0 {
+			field := fields[0]
+			switch {
+			case field == "-u" || field == "--unset" || field == "-C" || field == "--chdir":
+				fields = fields[min(2, len(fields)):] // Option with a value
+			case strings.HasPrefix(field, "-") || strings.Contains(field, "="):
+				fields = fields[1:] // e.g. -S, -i or NAME=value
+			default:
+				goto interpreter
+			}
+		}
+		return nil, false
+	}
+
+interpreter:
+	if len(fields) == 0 || filepath.Base(fields[0]) != appname {
+		return nil, false
+	}
+	return fields[1:], true
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+// summarize writes on stderr the summary of the operations of the run and, if filename is given,
+// exports it as JSON ("-" for stdout).
+func summarize(filename string) error {
+	r := primitive.RunReport()
+
+	if len(r.Operations) > 0 && primitive.Logger().Enabled(context.Background(), slog.LevelInfo) {
+		if primitive.LogFormat() == primitive.LogFormatText {
+			r.WriteSummary(os.Stderr)
+		} else {
+			primitive.Logger().Info("Summary", "changed", r.Changed, "unchanged", r.Unchanged, "failed", r.Failed, "duration", r.Duration)
+		}
+	}
+
+	if filename == "" {
+		return nil
+	}
+
+	payload, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	payload = append(payload, '\n')
+
+	if filename == "-" {
+		_, err = os.Stdout.Write(payload)
+		return err
+	}
+	return os.WriteFile(filename, payload, 0644)
+}
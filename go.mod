@@ -10,11 +10,13 @@ require (
 	github.com/d5/tengo/v2 v2.17.0
 	github.com/direnv/direnv/v2 v2.35.0
 	github.com/mdouchement/upathex v0.1.0
+	github.com/peterh/liner v1.2.2
 	github.com/pkg/errors v0.9.1
 	github.com/spf13/cobra v1.9.1
 	github.com/vbauerster/mpb/v8 v8.9.3
 	github.com/zeebo/xxh3 v1.0.2
 	golang.org/x/crypto v0.36.0
+	golang.org/x/term v0.30.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -0,0 +1,89 @@
+// Package binding declares the functions shared by the Lua and Tengo libraries.
+//
+// Each function is defined once as a plain Go function and adapted to both languages
+// by lualib and tengolib, so they cannot drift apart.
+package binding
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// A Function is a Go function exposed to the scripts.
+//
+// Func parameters can be string, int, int64, float64, bool, FileMode, []string, Callback, any,
+// and the last one can be variadic. Func returns any number of values of these types, []byte or *Object,
+// or a sole Results, optionally followed by an error. A []byte is a string in Lua and bytes in Tengo.
+// In Lua the error is raised and the values are returned, in Tengo the error is returned as an error value
+// and several values are returned as an array.
+type Function struct {
+	Name string
+	Func any
+}
+
+// An Object is a value with methods, like a job handle.
+// It is a table whose methods are called with `obj:method()` in Lua and a map called with `obj.method()` in Tengo.
+type Object struct {
+	Fields  map[string]any
+	Methods []Function
+}
+
+// A Callback is a function of the script given as argument, like the one called by `os.indir(dir, fn)`.
+// The errors raised by the script are not returned, they abort the bound function like in the script.
+type Callback func() (Results, error)
+
+// Results are the values returned by a Callback, opaque to Go.
+// Returned by the bound function, they are given back unchanged to the script.
+type Results struct {
+	Values any
+}
+
+// A FileMode is a permission given as `755` in Lua (no octal literal) and `0755` in Tengo.
+type FileMode uint32
+
+// Modules are the bound functions indexed by module name (e.g. `os` for `lualib/os` and `os`).
+var Modules = map[string][]Function{
+	"filepath": filepathFunctions,
+	"http":     httpFunctions,
+	"log":      logFunctions,
+	"os":       osFunctions,
+	"prompt":   promptFunctions,
+	"strings":  stringsFunctions,
+	"sudo":     sudoFunctions,
+	"times":    timesFunctions,
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Signature returns the reflected function with its parameter types and its result types without the trailing error.
+// It panics if Func is not a function, bindings are static so it is a programming error.
+func (f Function) Signature() (fn reflect.Value, params []reflect.Type, results []reflect.Type) {
+	fn = reflect.ValueOf(f.Func)
+	t := fn.Type()
+	if t.Kind() != reflect.Func {
+		panic(fmt.Sprintf("binding: %s is not a function", f.Name))
+	}
+
+	for i := 0; i < t.NumIn(); i++ {
+		params = append(params, t.In(i))
+	}
+
+	for i := 0; i < t.NumOut(); i++ {
+		if i == t.NumOut()-1 && t.Out(i) == errorType {
+			break
+		}
+		results = append(results, t.Out(i))
+	}
+
+	return fn, params, results
+}
+
+// Call calls the function with the given arguments and splits its results from its error.
+func Call(fn reflect.Value, in []reflect.Value) ([]reflect.Value, error) {
+	out := fn.Call(in)
+	if n := len(out); n > 0 && out[n-1].Type() == errorType {
+		err, _ := out[n-1].Interface().(error)
+		return out[:n-1], err
+	}
+	return out, nil
+}
@@ -0,0 +1,291 @@
+package binding
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+// run runs the command described by the given options, a non-zero exit status is not an error.
+//
+// Options: command, args, dir, env, clear_env, stdin (string or bytes), stdin_file,
+// timeout ("30s" or seconds), output (capture, stream, tee or quiet), prefix and timestamps.
+// The result has stdout, stderr, exit_code, duration (ns), signal and timed_out.
+func run(options map[string]any) (map[string]any, error) {
+	c, closer, err := commandOf(options)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	r := &primitive.CommandResult{}
+	err = primitive.Operate("run", c.String(), nil, func() error {
+		if primitive.DryRun("run", c.String()) {
+			return nil
+		}
+
+		r, err = primitive.Exec(c)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resultMap(r), nil
+}
+
+// commandOf returns the command described by the given options and a function releasing its stdin file.
+func commandOf(options map[string]any) (c primitive.Command, closer func(), err error) {
+	closer = func() {}
+
+	var ok bool
+	if c.Name, ok = options["command"].(string); !ok || c.Name == "" {
+		return c, closer, fmt.Errorf("run: command is required")
+	}
+
+	if c.Args, err = stringsOption(options, "args"); err != nil {
+		return c, closer, err
+	}
+
+	if v, ok := options["dir"]; ok {
+		if c.Dir, ok = v.(string); !ok {
+			return c, closer, fmt.Errorf("run: dir must be a string")
+		}
+	}
+
+	if v, ok := options["env"]; ok {
+		env, ok := v.(map[string]any)
+		if !ok {
+			return c, closer, fmt.Errorf("run: env must be a map")
+		}
+
+		c.Env = make(map[string]string, len(env))
+		for k, v := range env {
+			c.Env[k] = fmt.Sprint(v)
+		}
+	}
+
+	c.ClearEnv, _ = options["clear_env"].(bool)
+	c.Timestamps, _ = options["timestamps"].(bool)
+
+	if v, ok := options["prefix"]; ok {
+		if c.Prefix, ok = v.(string); !ok {
+			return c, closer, fmt.Errorf("run: prefix must be a string")
+		}
+	}
+
+	if v, ok := options["output"]; ok {
+		if c.Output, ok = v.(string); !ok {
+			return c, closer, fmt.Errorf("run: output must be a string")
+		}
+	}
+
+	if v, ok := options["timeout"]; ok {
+		if c.Timeout, err = durationOption(v); err != nil {
+			return c, closer, fmt.Errorf("run: timeout: %w", err)
+		}
+	}
+
+	c.Stdin, closer, err = stdinOf(options)
+	return c, closer, err
+}
+
+// stdinOf returns the reader described by the stdin and stdin_file options and a function releasing it.
+func stdinOf(options map[string]any) (stdin io.Reader, closer func(), err error) {
+	closer = func() {}
+
+	switch v := options["stdin"].(type) {
+	case nil:
+	case string:
+		stdin = strings.NewReader(v)
+	case []byte:
+		stdin = bytes.NewReader(v)
+	default:
+		return nil, closer, fmt.Errorf("run: stdin must be a string or bytes")
+	}
+
+	if v, ok := options["stdin_file"]; ok {
+		name, ok := v.(string)
+		if !ok {
+			return nil, closer, fmt.Errorf("run: stdin_file must be a string")
+		}
+
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, closer, err
+		}
+		stdin = f
+		closer = func() { f.Close() }
+	}
+
+	return stdin, closer, nil
+}
+
+func resultMap(r *primitive.CommandResult) map[string]any {
+	var signal any
+	if r.Signal != "" {
+		signal = r.Signal
+	}
+
+	return map[string]any{
+		"stdout":    string(r.Stdout),
+		"stderr":    string(r.Stderr),
+		"exit_code": int64(r.ExitCode),
+		"duration":  int64(r.Duration),
+		"signal":    signal,
+		"timed_out": r.TimedOut,
+	}
+}
+
+// stringsOption returns the given array option.
+func stringsOption(options map[string]any, name string) ([]string, error) {
+	v, ok := options[name]
+	if !ok {
+		return nil, nil
+	}
+
+	switch v := v.(type) {
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			values = append(values, fmt.Sprint(e))
+		}
+		return values, nil
+	case map[string]any:
+		if len(v) == 0 {
+			return nil, nil // An empty Lua table
+		}
+	}
+
+	return nil, fmt.Errorf("run: %s must be an array", name)
+}
+
+// durationOption parses a duration given as a string (e.g. 1m30s) or a number of seconds.
+func durationOption(v any) (time.Duration, error) {
+	switch v := v.(type) {
+	case string:
+		return time.ParseDuration(v)
+	case int64:
+		return time.Duration(v) * time.Second, nil
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	default:
+		return 0, fmt.Errorf("invalid duration: %v", v)
+	}
+}
+
+// pipeline runs the commands described by the given options, each one reading the output of the previous one.
+//
+// Options: commands (an array of os.run options, without stdin and output), stdin (string or bytes), stdin_file,
+// stdout_file, append (to stdout_file), pipefail and timeout.
+// The result has stdout, stderr, exit_code, pipestatus, duration (ns) and timed_out.
+func pipeline(options map[string]any) (map[string]any, error) {
+	var p primitive.Pipeline
+
+	commands, ok := options["commands"].([]any)
+	if !ok || len(commands) == 0 {
+		return nil, fmt.Errorf("pipeline: commands must be a non-empty array")
+	}
+
+	for i, v := range commands {
+		o, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("pipeline: commands[%d] must be a map", i)
+		}
+
+		c, closer, err := commandOf(o)
+		closer()
+		if err != nil {
+			return nil, fmt.Errorf("pipeline: commands[%d]: %w", i, err)
+		}
+		p.Commands = append(p.Commands, c)
+	}
+
+	p.Pipefail, _ = options["pipefail"].(bool)
+
+	if v, ok := options["timeout"]; ok {
+		var err error
+		if p.Timeout, err = durationOption(v); err != nil {
+			return nil, fmt.Errorf("pipeline: timeout: %w", err)
+		}
+	}
+
+	stdin, closer, err := stdinOf(options)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+	p.Stdin = stdin
+
+	line := p.String()
+	if name, ok := options["stdin_file"].(string); ok {
+		line += " < " + name
+	}
+
+	var stdout string
+	if v, ok := options["stdout_file"]; ok {
+		if stdout, ok = v.(string); !ok {
+			return nil, fmt.Errorf("pipeline: stdout_file must be a string")
+		}
+
+		if a, _ := options["append"].(bool); a {
+			line += " >> " + stdout
+		} else {
+			line += " > " + stdout
+		}
+	}
+
+	r := &primitive.PipelineResult{PipeStatus: make([]int, len(p.Commands))}
+	err = primitive.Operate("pipeline", line, nil, func() error {
+		if primitive.DryRun("pipeline", line) {
+			return nil
+		}
+
+		if stdout != "" {
+			if err := primitive.Track("pipeline", stdout); err != nil {
+				return err
+			}
+
+			flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+			if a, _ := options["append"].(bool); a {
+				flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+			}
+
+			f, err := os.OpenFile(stdout, flag, 0o644)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			p.Stdout = f
+		}
+
+		r, err = primitive.ExecPipeline(p)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pipelineResultMap(r), nil
+}
+
+func pipelineResultMap(r *primitive.PipelineResult) map[string]any {
+	pipestatus := make([]any, 0, len(r.PipeStatus))
+	for _, code := range r.PipeStatus {
+		pipestatus = append(pipestatus, int64(code))
+	}
+
+	return map[string]any{
+		"stdout":     string(r.Stdout),
+		"stderr":     string(r.Stderr),
+		"exit_code":  int64(r.ExitCode),
+		"pipestatus": pipestatus,
+		"duration":   int64(r.Duration),
+		"timed_out":  r.TimedOut,
+	}
+}
@@ -0,0 +1,11 @@
+package binding
+
+import "github.com/mdouchement/upathex"
+
+var filepathFunctions = []Function{
+	{
+		// filepath.expand_tilde("~/.go/bin/")
+		Name: "expand_tilde",
+		Func: upathex.ExpandTilde,
+	},
+}
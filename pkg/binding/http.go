@@ -0,0 +1,42 @@
+package binding
+
+import (
+	"net/url"
+	"path"
+
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+var httpFunctions = []Function{
+	{
+		// http.join("https://localhost", "to", "file") => "https://localhost/to/file"
+		Name: "join",
+		Func: func(base string, elem ...string) (string, error) {
+			uri, err := url.Parse(base)
+			if err != nil {
+				return "", err
+			}
+			uri.Path = path.Join(uri.Path, path.Join(elem...))
+
+			return uri.String(), nil
+		},
+	},
+	{
+		// http.download("https://localhost/file", "/tmp/file", true)
+		// => true for displaying progress bar
+		Name: "download",
+		Func: func(url, dst string, progress bool) error {
+			return primitive.Operate("download", dst, nil, func() error {
+				if primitive.DryRun("download", url, dst) {
+					return nil
+				}
+
+				if err := primitive.Track("download", dst); err != nil {
+					return err
+				}
+
+				return primitive.Download(url, dst, progress)
+			})
+		},
+	},
+}
@@ -0,0 +1,121 @@
+package binding
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+// signals are the signals accepted by name, the other ones are given by number.
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"TERM": syscall.SIGTERM,
+}
+
+// spawn starts the command described by the given os.run options in the background and returns its handle.
+// Leftover jobs are stopped when the run exits, unless they are detached.
+//
+// The handle has pid and command, and the methods wait(), kill(signal), running(), stdout(), stderr() and detach().
+// The spawn option `detached = true` detaches the job as soon as it is started.
+func spawn(options map[string]any) (*Object, error) {
+	c, closer, err := commandOf(options)
+	if err != nil {
+		return nil, err
+	}
+	defer closer()
+
+	detached, _ := options["detached"].(bool)
+
+	job := &primitive.Job{}
+	err = primitive.Operate("spawn", c.String(), nil, func() error {
+		if primitive.DryRun("spawn", c.String()) {
+			return nil
+		}
+
+		job, err = primitive.Spawn(c)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if detached {
+		job.Detach()
+	}
+
+	return jobObject(job), nil
+}
+
+// jobObject returns the handle of the given job.
+func jobObject(job *primitive.Job) *Object {
+	return &Object{
+		Fields: map[string]any{
+			"pid":     int64(job.Pid()),
+			"command": job.String(),
+		},
+		Methods: []Function{
+			{
+				// Waits for the job to exit and returns the same result as os.run.
+				Name: "wait",
+				Func: func() (map[string]any, error) {
+					r, err := job.Wait()
+					if err != nil {
+						return nil, err
+					}
+					return resultMap(r), nil
+				},
+			},
+			{
+				// Sends a signal given by name ("TERM" by default, "SIGKILL", ...) or number.
+				Name: "kill",
+				Func: func(signal ...any) error {
+					if len(signal) == 0 {
+						return job.Kill(syscall.SIGTERM)
+					}
+
+					sig, err := signalOf(signal[0])
+					if err != nil {
+						return err
+					}
+					return job.Kill(sig)
+				},
+			},
+			{
+				Name: "running",
+				Func: job.Running,
+			},
+			{
+				// The output captured so far.
+				Name: "stdout",
+				Func: func() string { return string(job.Stdout()) },
+			},
+			{
+				Name: "stderr",
+				Func: func() string { return string(job.Stderr()) },
+			},
+			{
+				Name: "detach",
+				Func: job.Detach,
+			},
+		},
+	}
+}
+
+// signalOf returns the signal given by name or number.
+func signalOf(v any) (syscall.Signal, error) {
+	switch v := v.(type) {
+	case int64:
+		return syscall.Signal(v), nil
+	case string:
+		if sig, ok := signals[strings.TrimPrefix(strings.ToUpper(v), "SIG")]; ok {
+			return sig, nil
+		}
+	}
+
+	return 0, fmt.Errorf("kill: unsupported signal: %v", v)
+}
@@ -0,0 +1,40 @@
+package binding
+
+import (
+	"log/slog"
+
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+// logFunctions write on stderr with the level and format given by --log-level and --log-format.
+var logFunctions = []Function{
+	{
+		// log.debug("resolved", {path = p})
+		Name: "debug",
+		Func: logger(slog.LevelDebug),
+	},
+	{
+		// log.info("installing", {package = "git", version = "2.43"})
+		Name: "info",
+		Func: logger(slog.LevelInfo),
+	},
+	{
+		Name: "warn",
+		Func: logger(slog.LevelWarn),
+	},
+	{
+		Name: "error",
+		Func: logger(slog.LevelError),
+	},
+}
+
+// logger returns a function logging a message with optional fields at the given level.
+func logger(level slog.Level) func(msg string, fields ...map[string]any) {
+	return func(msg string, fields ...map[string]any) {
+		var f map[string]any
+		if len(fields) > 0 {
+			f = fields[0]
+		}
+		primitive.Log(level, msg, f)
+	}
+}
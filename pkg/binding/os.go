@@ -0,0 +1,760 @@
+package binding
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/direnv/direnv/v2/pkg/dotenv"
+	"github.com/mdouchement/ldt/pkg/primitive"
+	"github.com/mdouchement/ldt/pkg/primitive/archive"
+	"github.com/mdouchement/upathex"
+)
+
+var osFunctions = []Function{
+	{
+		// os.osname() => "linux"
+		Name: "osname",
+		Func: func() string {
+			return runtime.GOOS
+		},
+	},
+	{
+		// os.user_exists("myuser")
+		Name: "user_exists",
+		Func: func(username string) bool {
+			_, err := user.Lookup(username)
+			return err == nil
+		},
+	},
+	{
+		// os.user_id("myuser") => "1001"
+		Name: "user_id",
+		Func: func(username string) (string, error) {
+			u, err := user.Lookup(username)
+			if err != nil {
+				return "", err
+			}
+
+			return u.Uid, nil
+		},
+	},
+	{
+		// os.group_id("mygroup") => "1001"
+		Name: "group_id",
+		Func: func(groupname string) (string, error) {
+			g, err := user.LookupGroup(groupname)
+			if err != nil {
+				return "", err
+			}
+
+			return g.Gid, nil
+		},
+	},
+	{
+		// os.exist("~/tmp/binary")
+		Name: "exist",
+		Func: primitive.Exist,
+	},
+	{
+		// os.touch("/tmp/ldt.db")
+		Name: "touch",
+		Func: func(filename string) error {
+			return primitive.Operate("touch", filename, func() bool { return primitive.Exist(filename) }, func() error {
+				if primitive.DryRun("touch", filename) {
+					return nil
+				}
+
+				if err := primitive.TrackCreation("touch", filename); err != nil {
+					return err
+				}
+
+				f, err := os.Create(filename)
+				if err != nil {
+					return err
+				}
+				return f.Close()
+			})
+		},
+	},
+	{
+		// os.chmod("~/tmp/binary", 755)  -- Lua
+		// os.chmod("~/tmp/binary", 0755) // Tengo
+		Name: "chmod",
+		Func: func(name string, mode FileMode) error {
+			return primitive.Operate("chmod", name, func() bool { return primitive.SameMode(name, os.FileMode(mode)) }, func() error {
+				if primitive.DryRun("chmod", name, fmt.Sprintf("%04o", mode)) {
+					return nil
+				}
+
+				if err := primitive.TrackAttributes("chmod", name); err != nil {
+					return err
+				}
+
+				return os.Chmod(name, os.FileMode(mode))
+			})
+		},
+	},
+	{
+		// os.chown("~/tmp/binary", 1001, 1001)
+		// os.chown("~/tmp/binary", 1001, 1001, true) -- recursive, like os.chown_r
+		Name: "chown",
+		Func: func(name string, uid, gid int, recursive ...bool) error {
+			if len(recursive) > 0 && recursive[0] {
+				return chownR(name, uid, gid)
+			}
+
+			return primitive.Operate("chown", name, func() bool { return primitive.SameOwner(name, uid, gid) }, func() error {
+				if primitive.DryRun("chown", name, uid, gid) {
+					return nil
+				}
+
+				if err := primitive.TrackAttributes("chown", name); err != nil {
+					return err
+				}
+
+				return os.Chown(name, uid, gid)
+			})
+		},
+	},
+	{
+		// os.chown_r("~/tmp", 1001, 1001)
+		Name: "chown_r",
+		Func: chownR,
+	},
+	{
+		// os.mkdir("~/tmp/something")       -- 755 by default
+		// os.mkdir("~/tmp/something", 0700) // Tengo
+		Name: "mkdir",
+		Func: func(name string, perm ...FileMode) error {
+			return primitive.Operate("mkdir", name, nil, func() error {
+				if primitive.DryRun("mkdir", name) {
+					return nil
+				}
+
+				if err := primitive.TrackCreation("mkdir", name); err != nil {
+					return err
+				}
+
+				return os.Mkdir(name, os.FileMode(permission(perm)))
+			})
+		},
+	},
+	{
+		// os.mkdir_all("~/tmp/some/thing")       -- 755 by default
+		// os.mkdir_all("~/tmp/some/thing", 0700) // Tengo
+		Name: "mkdir_all",
+		Func: mkdirAll,
+	},
+	{
+		// os.mkdir_p("~/tmp/some/thing") -- alias of mkdir_all
+		Name: "mkdir_p",
+		Func: mkdirAll,
+	},
+	{
+		// os.cp("go.mod", "/tmp")
+		Name: "cp",
+		Func: func(src, dst string) error {
+			return primitive.Operate("cp", dst, func() bool { return primitive.SameContent(src, dst) }, func() error {
+				if primitive.DryRun("cp", src, dst) {
+					return nil
+				}
+
+				if err := primitive.Track("cp", dst); err != nil {
+					return err
+				}
+
+				return primitive.Copy(src, dst)
+			})
+		},
+	},
+	{
+		// os.cp_rf(".", "/tmp/project")
+		Name: "cp_rf",
+		Func: func(src, dst string) error {
+			return primitive.Operate("cp_rf", dst, nil, func() error {
+				if primitive.DryRun("cp_rf", src, dst) {
+					return nil
+				}
+
+				if err := primitive.Track("cp_rf", dst); err != nil {
+					return err
+				}
+
+				return primitive.CopyRF(src, dst)
+			})
+		},
+	},
+	{
+		// os.mv("/src", "/dst") -- moved into /dst if it is a directory
+		Name: "mv",
+		Func: func(src, dst string) error {
+			return primitive.Operate("mv", dst, nil, func() error {
+				stat, err := os.Stat(dst)
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+
+				if err == nil && stat.IsDir() {
+					dst = filepath.Join(dst, filepath.Base(src))
+				}
+
+				if primitive.DryRun("mv", src, dst) {
+					return nil
+				}
+
+				if err := primitive.Track("mv", dst); err != nil {
+					return err
+				}
+				if err := primitive.TrackMove(src, dst); err != nil {
+					return err
+				}
+
+				return os.Rename(src, dst)
+			})
+		},
+	},
+	{
+		// os.remove("go.mod")
+		Name: "remove",
+		Func: remove,
+	},
+	{
+		// os.rm("go.mod") -- alias of remove
+		Name: "rm",
+		Func: remove,
+	},
+	{
+		// os.remove_all("/tmp/project")
+		Name: "remove_all",
+		Func: removeAll,
+	},
+	{
+		// os.rm_rf("/tmp/project") -- alias of remove_all
+		Name: "rm_rf",
+		Func: removeAll,
+	},
+	{
+		// os.write_file("go.mod", payload)
+		Name: "write_file",
+		Func: func(filename, payload string) error {
+			return primitive.Operate("write_file", filename, func() bool { return primitive.SameBytes(filename, []byte(payload)) }, func() error {
+				if primitive.DryRun("write_file", filename, fmt.Sprintf("(%d bytes)", len(payload))) {
+					return nil
+				}
+
+				if err := primitive.Track("write_file", filename); err != nil {
+					return err
+				}
+
+				return os.WriteFile(filename, []byte(payload), 0644)
+			})
+		},
+	},
+	{
+		// os.verify_checksum("sha256", "ldt.tar.gz", "9f86d08...")
+		Name: "verify_checksum",
+		Func: func(algorithm, filename, expected string) error {
+			alg := primitive.ChecksumAlg(algorithm)
+
+			f, err := os.Open(filename)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			hashes, err := primitive.Checksum(f, alg)
+			if err != nil {
+				return err
+			}
+
+			actual := hex.EncodeToString(hashes[alg].Sum(nil))
+			if !strings.EqualFold(actual, expected) {
+				return &primitive.ChecksumMismatchError{Path: filename, Expected: expected, Actual: actual}
+			}
+			return nil
+		},
+	},
+	{
+		// os.load_direnv(".envrc") -- the environment is restored on exit if it is not unloaded
+		Name: "load_direnv",
+		Func: loadDirenv,
+	},
+	{
+		// os.unload_direnv(".envrc")
+		Name: "unload_direnv",
+		Func: unloadDirenv,
+	},
+	{
+		// os.symlink("/tmp/ldt.db", "link.db")
+		Name: "symlink",
+		Func: func(oldname, newname string) error {
+			unchanged := func() bool {
+				target, err := os.Readlink(newname)
+				return err == nil && target == oldname
+			}
+
+			return primitive.Operate("symlink", newname, unchanged, func() error {
+				if primitive.DryRun("symlink", oldname, newname) {
+					return nil
+				}
+
+				if err := primitive.Track("symlink", newname); err != nil {
+					return err
+				}
+
+				return os.Symlink(oldname, newname)
+			})
+		},
+	},
+	{
+		// os.chmod_r("~/.ssh", 700)  -- Lua
+		// os.chmod_r("~/.ssh", 0700) // Tengo
+		Name: "chmod_r",
+		Func: func(root string, mode FileMode) error {
+			return primitive.Operate("chmod_r", root, nil, func() error {
+				if primitive.DryRun("chmod_r", root, fmt.Sprintf("%04o", mode)) {
+					return nil
+				}
+
+				return filepath.Walk(root, func(path string, _ os.FileInfo, err error) error {
+					if err != nil {
+						return err
+					}
+					if err := primitive.TrackAttributes("chmod", path); err != nil {
+						return err
+					}
+					return os.Chmod(path, os.FileMode(mode))
+				})
+			})
+		},
+	},
+	{
+		// os.exec_in("/workdir", "useradd", "--no-create-home", "--shell", "/sbin/nologin", "myuser")
+		// os.exec_in("/workdir", "git commit -m 'first commit'") -- a single string is split like a shell does
+		Name: "exec_in",
+		Func: func(workdir, name string, args ...string) (string, error) {
+			name, args, err := primitive.SplitCommand(name, args)
+			if err != nil {
+				return "", err
+			}
+
+			// The output is streamed while it is captured.
+			var std bytes.Buffer
+			err = primitive.Operate("exec_in", primitive.ShellQuote(append([]string{name}, args...)...), nil, func() error {
+				if primitive.DryRun("exec_in", workdir, name, strings.Join(args, " ")) {
+					return nil
+				}
+
+				cmd := exec.Command(name, args...)
+				cmd.Dir = workdir
+				cmd.Stdout = io.MultiWriter(&std, os.Stdout)
+				cmd.Stderr = io.MultiWriter(&std, os.Stderr)
+				return primitive.RunCommand(cmd)
+			})
+			if err != nil {
+				return "", err
+			}
+
+			return std.String(), nil
+		},
+	},
+	{
+		// local stdout, stderr = os.exec_catched("useradd", "--no-create-home", "--shell", "/sbin/nologin", "myuser")
+		// A single string is split like a shell does.
+		// Empty outputs are nil (undefined in Tengo), the stderr is prefixed by the error if the command fails.
+		Name: "exec_catched",
+		Func: func(name string, args ...string) (stdout, stderr any) {
+			name, args, err := primitive.SplitCommand(name, args)
+			if err != nil {
+				return nil, err.Error()
+			}
+
+			var out, errout bytes.Buffer
+			err = primitive.Operate("exec_catched", primitive.ShellQuote(append([]string{name}, args...)...), nil, func() error {
+				if primitive.DryRun("exec_catched", name, strings.Join(args, " ")) {
+					return nil
+				}
+
+				cmd := exec.Command(name, args...)
+				cmd.Stdout = &out
+				cmd.Stderr = &errout
+				return primitive.RunCommand(cmd)
+			})
+			if err != nil {
+				std := errout.String()
+				errout.Reset()
+				errout.WriteString(err.Error() + ": " + std)
+			}
+
+			return optional(out.String()), optional(errout.String())
+		},
+	},
+	{
+		// local r = os.run({command = "grep", args = {"-q", "foo", "bar.txt"}})
+		// if r.exit_code == 1 then ... end
+		Name: "run",
+		Func: run,
+	},
+	{
+		// local r = os.pipeline({commands = {{command = "ps", args = {"aux"}}, {command = "grep", args = {"ldt"}}}, pipefail = true})
+		// print(r.exit_code, r.pipestatus[1], r.pipestatus[2])
+		Name: "pipeline",
+		Func: pipeline,
+	},
+	{
+		// local db = os.spawn({command = "redis-server", args = {"--port", "6380"}})
+		// ... db:kill("TERM"); db:wait()
+		Name: "spawn",
+		Func: spawn,
+	},
+	{
+		// os.archive("backup.tar.gz", "~/.ssh", "~/.gnupg")
+		Name: "archive",
+		Func: func(name, path string, paths ...string) error {
+			return primitive.Operate("archive", name, nil, func() error {
+				if !primitive.IsArchiveSupported(name) {
+					return errors.New("unsupported archive format")
+				}
+
+				filenames := append([]string{path}, paths...)
+
+				var base string
+				for _, root := range filenames {
+					info, err := os.Stat(root)
+					if err != nil {
+						return err
+					}
+
+					if !info.IsDir() {
+						root = filepath.Dir(root)
+					}
+
+					if base == "" {
+						base = root
+						continue
+					}
+					base = primitive.LongestCommonPathPrefix(base, root)
+				}
+
+				var files []*archive.File
+				for _, root := range filenames {
+					fs, err := archive.FilesFromDisk(root, archive.FilesFromDiskOptions{
+						GlobalPrefix: base,
+						Exclude:      regexp.MustCompile(name + "$"),
+					})
+					if err != nil {
+						return err
+					}
+
+					files = append(files, fs...)
+				}
+
+				if primitive.DryRun("archive", name, strings.Join(filenames, " ")) {
+					return nil
+				}
+
+				if err := primitive.Track("archive", name); err != nil {
+					return err
+				}
+
+				//
+
+				f, err := os.Create(name)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				codec, err := primitive.NewArchiveWriter(name, f)
+				if err != nil {
+					return err
+				}
+
+				if err = codec.Archives(files); err != nil {
+					return err
+				}
+
+				if err = codec.Close(); err != nil {
+					return err
+				}
+
+				if err = f.Sync(); err != nil && !strings.HasSuffix(err.Error(), "operation not supported") {
+					return err
+				}
+
+				return nil
+			})
+		},
+	},
+	{
+		// os.extract_archive("backup.tar.gz") -- in the current directory
+		Name: "extract_archive",
+		Func: func(name string) error {
+			return primitive.Operate("extract_archive", name, nil, func() error {
+				pwd, err := os.Getwd()
+				if err != nil {
+					return err
+				}
+
+				if primitive.DryRun("extract_archive", name, pwd) {
+					return nil
+				}
+
+				return extractArchive(name, trackedHandler(pwd, archive.FileToDiskHandler(pwd)))
+			})
+		},
+	},
+	{
+		// os.check_archive("backup.tar.gz")
+		Name: "check_archive",
+		Func: func(name string) error {
+			handler := func(t archive.Type, f *archive.File) error {
+				if t == archive.TypeDirectory {
+					return nil
+				}
+
+				r, err := f.Open()
+				if err != nil {
+					return err
+				}
+				defer r.Close()
+
+				n, err := io.Copy(io.Discard, r)
+				if err != nil {
+					return err
+				}
+
+				if n != f.Size() {
+					return fmt.Errorf("%s: bad size (%d->%d)", f.Name, f.Size(), n)
+				}
+
+				return err
+			}
+
+			return extractArchive(name, handler)
+		},
+	},
+	{
+		// os.read_asset("templates/gitconfig")
+		Name: "read_asset",
+		Func: primitive.ReadAsset,
+	},
+	{
+		// os.checksum("sha256", "/sbin/nologin")
+		Name: "checksum",
+		Func: func(algorithm, filename string) (string, error) {
+			alg := primitive.ChecksumAlg(algorithm)
+
+			f, err := os.Open(filename)
+			if err != nil {
+				return "", err
+			}
+			defer f.Close()
+
+			hashes, err := primitive.Checksum(f, alg)
+			if err != nil {
+				return "", err
+			}
+
+			return hex.EncodeToString(hashes[alg].Sum(nil)), nil
+		},
+	},
+	{
+		// os.expand_env("blah blah ${HOME} blah blah")
+		Name: "expand_env",
+		Func: upathex.ExpandEnv,
+	},
+	{
+		// os.indir("/workdir", function() return os.exec("make") end) -- Lua
+		// os.indir("/workdir", func() { return os.exec("make").run() }) // Tengo
+		// => fn is called with dir as working directory, its results are returned.
+		Name: "indir",
+		Func: func(workdir string, fn Callback) (Results, error) {
+			original, err := os.Getwd()
+			if err != nil {
+				return Results{}, err
+			}
+
+			if err := os.Chdir(workdir); err != nil {
+				return Results{}, err
+			}
+			defer os.Chdir(original) // Also when fn raises an error
+
+			return fn()
+		},
+	},
+}
+
+// envcache holds the environments loaded by load_direnv indexed by filename.
+var envcache = map[string]*primitive.Env{}
+
+// loadDirenv exports the variables of the given .envrc file.
+// The environment is restored on exit if the script has not unloaded it.
+func loadDirenv(filename string) error {
+	if _, ok := envcache[filename]; ok {
+		return fmt.Errorf("%s already in use", filename)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	envmap, err := dotenv.Parse(string(data))
+	if err != nil {
+		return err
+	}
+
+	env := primitive.NewEnv(envmap)
+	env.Export()
+
+	envcache[filename] = env
+
+	primitive.OnExit(func() error {
+		if envcache[filename] == env {
+			env.Restore()
+			delete(envcache, filename)
+		}
+		return nil
+	})
+
+	return nil
+}
+
+// unloadDirenv restores the environment changed by loadDirenv for the given file.
+func unloadDirenv(filename string) error {
+	env, ok := envcache[filename]
+	if !ok {
+		return fmt.Errorf("%s not loaded", filename)
+	}
+
+	env.Restore()
+	delete(envcache, filename)
+	return nil
+}
+
+func chownR(root string, uid, gid int) error {
+	return primitive.Operate("chown_r", root, nil, func() error {
+		if primitive.DryRun("chown_r", root, uid, gid) {
+			return nil
+		}
+
+		return filepath.WalkDir(root, func(path string, _ fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := primitive.TrackAttributes("chown_r", path); err != nil {
+				return err
+			}
+			return os.Chown(path, uid, gid)
+		})
+	})
+}
+
+func mkdirAll(name string, perm ...FileMode) error {
+	return primitive.Operate("mkdir_all", name, func() bool { return primitive.DirExist(name) }, func() error {
+		if primitive.DryRun("mkdir_all", name) {
+			return nil
+		}
+
+		if err := primitive.TrackCreation("mkdir_all", name); err != nil {
+			return err
+		}
+
+		return os.MkdirAll(name, os.FileMode(permission(perm)))
+	})
+}
+
+func remove(name string) error {
+	return primitive.Operate("remove", name, nil, func() error {
+		if primitive.DryRun("remove", name) {
+			return nil
+		}
+
+		if err := primitive.Track("remove", name); err != nil {
+			return err
+		}
+
+		return os.Remove(name)
+	})
+}
+
+func removeAll(name string) error {
+	return primitive.Operate("remove_all", name, func() bool { return primitive.Missing(name) }, func() error {
+		if primitive.DryRun("remove_all", name) {
+			return nil
+		}
+
+		if err := primitive.Track("remove_all", name); err != nil {
+			return err
+		}
+
+		return os.RemoveAll(name)
+	})
+}
+
+// permission returns the optional permission of a directory, 0755 by default.
+func permission(perm []FileMode) FileMode {
+	if len(perm) == 0 {
+		return 0755
+	}
+	return perm[0]
+}
+
+// optional returns nil for an empty string.
+func optional(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// trackedHandler records in the journal each file extracted to root.
+func trackedHandler(root string, handler archive.FileHandler) archive.FileHandler {
+	return func(t archive.Type, f *archive.File) error {
+		track := primitive.Track
+		if t == archive.TypeDirectory {
+			track = primitive.TrackCreation // The content of an existing directory is not replaced
+		}
+
+		if err := track("extract_archive", filepath.Join(root, filepath.FromSlash(f.Name))); err != nil {
+			return err
+		}
+
+		return handler(t, f)
+	}
+}
+
+func extractArchive(name string, handler archive.FileHandler) error {
+	if !primitive.IsArchiveSupported(name) {
+		return errors.New("unsupported archive format")
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	codec, err := primitive.NewArchiveReader(name, f)
+	if err != nil {
+		return err
+	}
+
+	if err = codec.Extract(handler); err != nil {
+		return err
+	}
+
+	return codec.Check()
+}
@@ -0,0 +1,60 @@
+package binding_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/d5/tengo/v2/stdlib"
+	"github.com/mdouchement/ldt/pkg/binding"
+	"github.com/mdouchement/ldt/pkg/lualib"
+	"github.com/mdouchement/ldt/pkg/tengolib"
+)
+
+// TestParity checks that the modules shared by Lua and Tengo expose the same functions in both languages.
+// The try_ variants are Lua-only by design and the Tengo's stdlib functions count as Tengo ones.
+func TestParity(t *testing.T) {
+	for module := range binding.Modules {
+		t.Run(module, func(t *testing.T) {
+			lua := map[string]bool{}
+			for _, fn := range lualib.Libraries["lualib/"+module] {
+				if !strings.HasPrefix(fn.Name, "try_") {
+					lua[fn.Name] = true
+				}
+			}
+
+			tengo := map[string]bool{}
+			for name, o := range tengolib.BuiltinModules[module] {
+				if o.CanCall() {
+					tengo[name] = true
+				}
+			}
+
+			var luaOnly, tengoOnly []string
+			for name := range lua {
+				if !tengo[name] && !inStdlib(module, name) {
+					luaOnly = append(luaOnly, name)
+				}
+			}
+			for name := range tengo {
+				if !lua[name] {
+					tengoOnly = append(tengoOnly, name)
+				}
+			}
+			slices.Sort(luaOnly)
+			slices.Sort(tengoOnly)
+
+			if len(luaOnly) > 0 {
+				t.Errorf("Lua-only functions: %s", strings.Join(luaOnly, ", "))
+			}
+			if len(tengoOnly) > 0 {
+				t.Errorf("Tengo-only functions: %s", strings.Join(tengoOnly, ", "))
+			}
+		})
+	}
+}
+
+func inStdlib(module, name string) bool {
+	o, ok := stdlib.BuiltinModules[module][name]
+	return ok && o.CanCall()
+}
@@ -0,0 +1,126 @@
+package binding
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+// promptFunctions ask the user. Their options are default, env (the variable read when ldt is not run
+// in a terminal) and validate (a regexp, only for input).
+var promptFunctions = []Function{
+	{
+		// local email = prompt.input("Git email", {validate = "^.+@.+$", env = "GIT_EMAIL"})
+		Name: "input",
+		Func: func(message string, options ...map[string]any) (string, error) {
+			p, err := promptOf(message, options)
+			if err != nil {
+				return "", err
+			}
+			return primitive.Input(p)
+		},
+	},
+	{
+		// local passphrase = prompt.password("Passphrase", {env = "PASSPHRASE"})
+		Name: "password",
+		Func: func(message string, options ...map[string]any) (string, error) {
+			p, err := promptOf(message, options)
+			if err != nil {
+				return "", err
+			}
+			return primitive.Password(p)
+		},
+	},
+	{
+		// if prompt.confirm("Install Docker?", {default = true}) then ... end
+		// It returns true without asking with the --yes flag.
+		Name: "confirm",
+		Func: func(message string, options ...map[string]any) (bool, error) {
+			p, err := promptOf(message, options)
+			if err != nil {
+				return false, err
+			}
+			return primitive.Confirm(p)
+		},
+	},
+	{
+		// local shell = prompt.select("Shell", {"bash", "zsh", "fish"}, {default = "zsh"})
+		Name: "select",
+		Func: func(message string, choices []string, options ...map[string]any) (string, error) {
+			p, err := promptOf(message, options)
+			if err != nil {
+				return "", err
+			}
+			return primitive.Select(p, choices)
+		},
+	},
+	{
+		// local tools = prompt.multi_select("Tools", {"fzf", "ripgrep", "jq"}, {default = {"jq"}})
+		Name: "multi_select",
+		Func: func(message string, choices []string, options ...map[string]any) ([]any, error) {
+			p, err := promptOf(message, options)
+			if err != nil {
+				return nil, err
+			}
+
+			selected, err := primitive.MultiSelect(p, choices)
+			if err != nil {
+				return nil, err
+			}
+
+			values := make([]any, 0, len(selected))
+			for _, s := range selected {
+				values = append(values, s)
+			}
+			return values, nil
+		},
+	},
+}
+
+// promptOf returns the prompt of the given message and options.
+func promptOf(message string, options []map[string]any) (primitive.Prompt, error) {
+	p := primitive.Prompt{Message: message}
+	if len(options) == 0 {
+		return p, nil
+	}
+	o := options[0]
+
+	switch v := o["default"].(type) {
+	case nil:
+	case bool:
+		p.Default = "no"
+		if v {
+			p.Default = "yes"
+		}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			values = append(values, fmt.Sprint(e))
+		}
+		p.Default = strings.Join(values, ",")
+	default:
+		p.Default = fmt.Sprint(v)
+	}
+
+	if v, ok := o["env"]; ok {
+		if p.Env, ok = v.(string); !ok {
+			return p, fmt.Errorf("prompt: env must be a string")
+		}
+	}
+
+	if v, ok := o["validate"]; ok {
+		expr, ok := v.(string)
+		if !ok {
+			return p, fmt.Errorf("prompt: validate must be a string")
+		}
+
+		var err error
+		if p.Validate, err = regexp.Compile(expr); err != nil {
+			return p, fmt.Errorf("prompt: validate: %w", err)
+		}
+	}
+
+	return p, nil
+}
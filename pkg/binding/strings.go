@@ -0,0 +1,73 @@
+package binding
+
+import (
+	"bufio"
+	"strings"
+
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+var stringsFunctions = []Function{
+	{
+		// strings.has_prefix("aa:bb", "aa:")
+		Name: "has_prefix",
+		Func: strings.HasPrefix,
+	},
+	{
+		// strings.has_sufix("aa:bb", ":bb")
+		Name: "has_sufix",
+		Func: strings.HasSuffix,
+	},
+	{
+		// strings.split("aa:bb", ":")
+		Name: "split",
+		Func: func(s, sep string) []any {
+			return values(strings.Split(s, sep))
+		},
+	},
+	{
+		// strings.join(":", "aa", "bb")
+		Name: "join",
+		Func: func(sep string, elems ...string) string {
+			return strings.Join(elems, sep)
+		},
+	},
+	{
+		// strings.lines("line1\nline\r\n")
+		Name: "lines",
+		Func: func(s string) ([]any, error) {
+			var lines []string
+			scanner := bufio.NewScanner(strings.NewReader(s))
+			for scanner.Scan() {
+				lines = append(lines, strings.TrimSpace(scanner.Text()))
+			}
+
+			return values(lines), scanner.Err()
+		},
+	},
+	{
+		// strings.shell_quote("ssh", host, "cat " .. path) -> ssh 'my host' 'cat /tmp/a b'
+		Name: "shell_quote",
+		Func: primitive.ShellQuote,
+	},
+	{
+		// strings.shell_split([[git commit -m "first commit"]]) -> {"git", "commit", "-m", "first commit"}
+		Name: "shell_split",
+		Func: func(cmdline string) ([]any, error) {
+			args, err := primitive.ShellSplit(cmdline)
+			if err != nil {
+				return nil, err
+			}
+			return values(args), nil
+		},
+	},
+}
+
+// values returns the given strings as a []any, the type of the arrays converted by both languages.
+func values(s []string) []any {
+	values := make([]any, 0, len(s))
+	for _, v := range s {
+		values = append(values, v)
+	}
+	return values
+}
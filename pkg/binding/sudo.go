@@ -0,0 +1,159 @@
+package binding
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+// sudoFunctions run a single operation as root through sudo or doas, the rest of the run stays unprivileged.
+var sudoFunctions = []Function{
+	{
+		// sudo.exec("apt-get", "install", "-y", "git")
+		// The output is streamed while it is captured.
+		Name: "exec",
+		Func: func(name string, args ...string) (string, error) {
+			c := primitive.Command{Name: name, Args: args, Output: primitive.OutputTee}
+
+			r := &primitive.CommandResult{}
+			err := primitive.Operate("sudo exec", c.String(), nil, func() error {
+				if primitive.DryRun("sudo exec", name, strings.Join(args, " ")) {
+					return nil
+				}
+
+				var err error
+				r, err = sudo(c)
+				return err
+			})
+			if err != nil {
+				return "", err
+			}
+
+			return string(r.Stdout) + string(r.Stderr), nil
+		},
+	},
+	{
+		// sudo.write_file("/etc/hosts.d/dev", payload)       -- an existing file keeps its mode and owner, a new one is 0644
+		// sudo.write_file("/etc/sudoers.d/dev", payload, 440) -- Lua
+		// sudo.write_file("/etc/sudoers.d/dev", payload, 0440) // Tengo
+		Name: "write_file",
+		Func: func(filename, payload string, mode ...FileMode) error {
+			unchanged := func() bool {
+				return primitive.SameBytes(filename, []byte(payload)) && (len(mode) == 0 || primitive.SameMode(filename, os.FileMode(mode[0])))
+			}
+
+			return primitive.Operate("sudo write_file", filename, unchanged, func() error {
+				if primitive.DryRun("sudo write_file", filename, fmt.Sprintf("(%d bytes)", len(payload))) {
+					return nil
+				}
+
+				if err := track(primitive.Track, "write_file", filename); err != nil {
+					return err
+				}
+
+				// The file is truncated in place, so an existing file keeps its mode and owner.
+				args := []string{"-c", `if [ -e "$1" ]; then cat > "$1"; else umask 022 && cat > "$1"; fi`, "sh", filename}
+				if len(mode) > 0 {
+					// The mode is set before writing so the payload is never readable with a wider one.
+					args = []string{"-c", `umask 077 && touch "$1" && chmod "$2" "$1" && cat > "$1"`, "sh", filename, fmt.Sprintf("%04o", mode[0])}
+				}
+
+				_, err := sudo(primitive.Command{
+					Name:  "sh",
+					Args:  args,
+					Stdin: strings.NewReader(payload),
+				})
+				return err
+			})
+		},
+	},
+	{
+		// sudo.cp("ldt.conf", "/etc/ldt.conf")
+		Name: "cp",
+		Func: func(src, dst string) error {
+			unchanged := func() bool { return primitive.SameContent(src, dst) }
+
+			return primitive.Operate("sudo cp", dst, unchanged, func() error {
+				if primitive.DryRun("sudo cp", src, dst) {
+					return nil
+				}
+
+				if err := track(primitive.Track, "cp", dst); err != nil {
+					return err
+				}
+
+				_, err := sudo(primitive.Command{Name: "cp", Args: []string{src, dst}})
+				return err
+			})
+		},
+	},
+	{
+		// sudo.chown("/srv/app", 1001, 1001)
+		// sudo.chown("/srv/app", 1001, 1001, true) -- recursive
+		Name: "chown",
+		Func: func(path string, uid, gid int, recursive ...bool) error {
+			r := len(recursive) > 0 && recursive[0]
+
+			var unchanged func() bool
+			if !r {
+				unchanged = func() bool { return primitive.SameOwner(path, uid, gid) }
+			}
+
+			return primitive.Operate("sudo chown", path, unchanged, func() error {
+				if primitive.DryRun("sudo chown", path, uid, gid, r) {
+					return nil
+				}
+
+				if err := track(primitive.TrackAttributes, "chown", path); err != nil {
+					return err
+				}
+
+				args := []string{strconv.Itoa(uid) + ":" + strconv.Itoa(gid), path}
+				if r {
+					args = append([]string{"-R"}, args...)
+				}
+
+				_, err := sudo(primitive.Command{Name: "chown", Args: args})
+				return err
+			})
+		},
+	},
+}
+
+// sudo runs the given command as root, a non-zero exit status is an error.
+func sudo(c primitive.Command) (*primitive.CommandResult, error) {
+	c, err := primitive.Sudo(c)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := primitive.Exec(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.ExitCode != 0 {
+		if msg := strings.TrimSpace(string(r.Stderr)); msg != "" {
+			return nil, fmt.Errorf("%s: %s", c.String(), msg)
+		}
+		return nil, fmt.Errorf("%s: exit status %d", c.String(), r.ExitCode)
+	}
+
+	return r, nil
+}
+
+// track records the given path in the journal.
+// Paths that ldt cannot read are not recorded, the privileged change cannot be rolled back then.
+func track(fn func(op, path string) error, op, path string) error {
+	err := fn("sudo "+op, path)
+	if errors.Is(err, fs.ErrPermission) {
+		primitive.Logger().Warn("not recorded in the journal", "path", path, "error", err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,17 @@
+package binding
+
+import (
+	"time"
+
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+var timesFunctions = []Function{
+	{
+		// times.duration_format(1500000000) => "1.5s"
+		Name: "duration_format",
+		Func: func(d int64) string {
+			return primitive.FormatDuration(time.Duration(d))
+		},
+	},
+}
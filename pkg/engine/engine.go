@@ -0,0 +1,192 @@
+// Package engine defines the scripting languages run by ldt.
+//
+// Each language is an Engine registered by the extension of its scripts.
+// Lua and Tengo are registered by default, embedders can register their own language with Register
+// and add a module to all the languages at once with Expose.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// An Engine compiles and runs the scripts of a language.
+type Engine interface {
+	// Name returns the name of the language (e.g. lua).
+	Name() string
+	// Extensions returns the extensions of the scripts, in precedence order (e.g. .lua).
+	Extensions() []string
+	// Expose makes the given module available to the scripts compiled afterwards.
+	Expose(module Module)
+	// Libraries returns the member names of the builtin and exposed modules, indexed by their import name
+	// (e.g. lualib/os). It is used to validate the scripts without running them.
+	Libraries() map[string][]string
+	// Compile compiles the given source.
+	Compile(src Source) (Program, error)
+}
+
+// A Program is a compiled script.
+type Program interface {
+	// Run runs the program until it completes or ctx is cancelled, in which case the cause of the cancellation is returned.
+	// args[0] is the name of the script, the other ones are given to the script.
+	Run(ctx context.Context, args []string) error
+}
+
+// A Source is the code of a script.
+type Source struct {
+	Name      string // Used in errors and stack traces, usually the filename
+	Code      []byte
+	ImportDir string // Base directory of the relative imports
+}
+
+// A Module is a set of functions exposed to the scripts.
+// It is imported with `require "lualib/<name>"` in Lua and `import("<name>")` in Tengo.
+type Module struct {
+	Name      string
+	Functions map[string]Function
+}
+
+// A Function is a Go function callable from the scripts.
+//
+// The arguments and the result are nil, bool, int64, float64, string, []byte,
+// []any or map[string]any. Returning an error raises it in Lua and returns an error value in Tengo.
+type Function func(args ...any) (any, error)
+
+var (
+	mu      sync.RWMutex
+	engines []Engine
+)
+
+func init() {
+	// Tengo comes first so its extensions take precedence over the Lua ones.
+	Register(Tengo)
+	Register(Lua)
+}
+
+// Register registers the given engine, it panics if one of its extensions is already registered.
+func Register(e Engine) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, ext := range e.Extensions() {
+		for _, registered := range engines {
+			for _, other := range registered.Extensions() {
+				if ext == other {
+					panic(fmt.Sprintf("engine: extension %s of %s already registered by %s", ext, e.Name(), registered.Name()))
+				}
+			}
+		}
+	}
+
+	engines = append(engines, e)
+}
+
+// Engines returns the registered engines in registration order.
+func Engines() []Engine {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return append([]Engine(nil), engines...)
+}
+
+// Extensions returns the extensions of all the registered engines in precedence order.
+func Extensions() []string {
+	var extensions []string
+	for _, e := range Engines() {
+		extensions = append(extensions, e.Extensions()...)
+	}
+	return extensions
+}
+
+// ByExtension returns the engine of the given extension (e.g. .lua), nil if none.
+func ByExtension(ext string) Engine {
+	for _, e := range Engines() {
+		for _, other := range e.Extensions() {
+			if ext == other {
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// ByName returns the engine of the given language name or extension without dot (e.g. tgo), nil if none.
+func ByName(name string) Engine {
+	for _, e := range Engines() {
+		if e.Name() == name {
+			return e
+		}
+	}
+	return ByExtension("." + name)
+}
+
+// Expose makes the given module available to all the registered engines.
+func Expose(module Module) {
+	for _, e := range Engines() {
+		e.Expose(module)
+	}
+}
+
+//
+//
+// Errors
+//
+//
+
+// An Error is the failure of a script with its call stack.
+type Error struct {
+	Message string
+	Frames  []Frame  // Innermost first
+	Causes  []string // Error chain of the Go side
+	Err     error
+}
+
+// A Frame is a location in the call stack of a script.
+type Frame struct {
+	File     string
+	Line     int
+	Column   int // 0 when unknown
+	Function string
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	for _, f := range e.Frames {
+		fmt.Fprintf(&b, "\n\tat %s", f)
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (f Frame) String() string {
+	s := fmt.Sprintf("%s:%d", f.File, f.Line)
+	if f.Column > 0 {
+		s += ":" + strconv.Itoa(f.Column)
+	}
+	if f.Function != "" {
+		s += " in " + f.Function
+	}
+	return s
+}
+
+// errorChain returns the messages of the errors wrapped by err.
+func errorChain(err error) []string {
+	var causes []string
+	previous := err.Error()
+	for err = errors.Unwrap(err); err != nil; err = errors.Unwrap(err) {
+		msg := err.Error()
+		if msg != previous {
+			causes = append(causes, msg)
+		}
+		previous = msg
+	}
+	return causes
+}
@@ -0,0 +1,287 @@
+package engine
+
+import (
+	"context"
+	"io/fs"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/go-lua"
+	"github.com/Shopify/goluago"
+	"github.com/Shopify/goluago/util"
+	"github.com/mdouchement/ldt/pkg/lualib"
+)
+
+// Lua is the engine of the Lua scripts.
+var Lua = &LuaEngine{}
+
+// A LuaEngine runs each program in a new state with the default libraries, goluago and lualib opened.
+type LuaEngine struct {
+	// Preload is the filesystem the Lua modules are required from before `package.path` (e.g. a bundle).
+	Preload fs.FS
+
+	mu      sync.Mutex
+	modules []Module
+}
+
+// Name implements Engine.
+func (e *LuaEngine) Name() string {
+	return "lua"
+}
+
+// Extensions implements Engine.
+func (e *LuaEngine) Extensions() []string {
+	return []string{".lua"}
+}
+
+// Expose implements Engine, the module is required with `require "lualib/<name>"`.
+func (e *LuaEngine) Expose(module Module) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.modules = append(e.modules, module)
+}
+
+// Libraries implements Engine, an exposed module replaces the lualib library of the same name.
+func (e *LuaEngine) Libraries() map[string][]string {
+	libraries := make(map[string][]string, len(lualib.Libraries))
+	for name, library := range lualib.Libraries {
+		for _, fn := range library {
+			libraries[name] = append(libraries[name], fn.Name)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, module := range e.modules {
+		names := make([]string, 0, len(module.Functions))
+		for name := range module.Functions {
+			names = append(names, name)
+		}
+		libraries["lualib/"+module.Name] = names
+	}
+
+	return libraries
+}
+
+// Compile implements Engine, the code is only checked since each run uses a new state.
+func (e *LuaEngine) Compile(src Source) (Program, error) {
+	l := lua.NewState()
+	if err := lua.LoadBuffer(l, string(src.Code), "@"+src.Name, ""); err != nil {
+		msg, _ := l.ToString(-1)
+		return nil, luaError(err, msg, nil)
+	}
+
+	return &luaProgram{engine: e, src: src}, nil
+}
+
+// NewState returns a new state with all the libraries and the exposed modules opened.
+func (e *LuaEngine) NewState() *lua.State {
+	l := lua.NewState()
+	lua.OpenLibraries(l)
+	goluago.Open(l)
+	lualib.Open(l)
+
+	e.mu.Lock()
+	modules := append([]Module(nil), e.modules...)
+	e.mu.Unlock()
+
+	for _, module := range modules {
+		openLuaModule(l, module)
+	}
+
+	if e.Preload != nil {
+		e.preload(l)
+	}
+
+	return l
+}
+
+// preload registers the Lua modules of the Preload filesystem in `package.preload`.
+func (e *LuaEngine) preload(l *lua.State) {
+	l.Global("package")
+	l.Field(-1, "preload")
+
+	_ = fs.WalkDir(e.Preload, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) != ".lua" {
+			return err
+		}
+
+		module := strings.TrimSuffix(strings.TrimSuffix(name, ".lua"), "/init")
+		module = strings.ReplaceAll(module, "/", ".")
+
+		l.PushGoFunction(func(l *lua.State) int {
+			code, err := fs.ReadFile(e.Preload, name)
+			if err != nil {
+				lua.Errorf(l, "%s", err.Error())
+			}
+
+			if err := lua.LoadBuffer(l, string(code), "@"+name, ""); err != nil {
+				lua.Errorf(l, "%s", lua.CheckString(l, -1))
+			}
+			l.Call(0, 1)
+			return 1
+		})
+		l.SetField(-2, module)
+		return nil
+	})
+
+	l.Pop(2)
+}
+
+type luaProgram struct {
+	engine *LuaEngine
+	src    Source
+}
+
+// Run implements Program, args are given to the script as the `arg` table.
+func (p *luaProgram) Run(ctx context.Context, args []string) error {
+	l := p.engine.NewState()
+
+	l.NewTable()
+	for i, arg := range args {
+		l.PushString(arg)
+		l.RawSetInt(-2, i)
+	}
+	l.SetGlobal("arg")
+
+	// Abort the script once the context is cancelled.
+	lua.SetDebugHook(l, func(l *lua.State, _ lua.Debug) {
+		if ctx.Err() != nil {
+			lua.Errorf(l, "%s", context.Cause(ctx).Error())
+		}
+	}, lua.MaskCount, 1000)
+
+	l.PushGoFunction(luaTraceback)
+	handler := l.Top()
+
+	if err := lua.LoadBuffer(l, string(p.src.Code), "@"+p.src.Name, ""); err != nil {
+		msg, _ := l.ToString(-1)
+		return luaError(err, msg, nil)
+	}
+
+	err := l.ProtectedCall(0, lua.MultipleReturns, handler)
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	if err != nil {
+		msg, _ := l.ToString(-1)
+		raised, _, _ := strings.Cut(msg, "\nstack traceback:")
+		return luaError(err, msg, lualib.RaisedError(l, raised))
+	}
+	return nil
+}
+
+// openLuaModule opens the given module as `lualib/<name>`.
+func openLuaModule(l *lua.State, module Module) {
+	library := make([]lua.RegistryFunction, 0, len(module.Functions))
+	for name, fn := range module.Functions {
+		library = append(library, lua.RegistryFunction{Name: name, Function: luaFunction(fn)})
+	}
+
+	open := func(l *lua.State) int {
+		lua.NewLibrary(l, library)
+		return 1
+	}
+	lua.Require(l, "lualib/"+module.Name, open, false)
+	l.Pop(1)
+}
+
+// luaFunction adapts the given function to Lua.
+func luaFunction(fn Function) lua.Function {
+	return func(l *lua.State) int {
+		args, err := util.PullVarargs(l, 1)
+		if err != nil {
+			lua.ArgumentError(l, 1, err.Error())
+		}
+		for i := range args {
+			args[i] = fromLua(args[i])
+		}
+
+		result, err := fn(args...)
+		if err != nil {
+			lua.Errorf(l, "%s", err.Error())
+		}
+
+		return util.DeepPush(l, result)
+	}
+}
+
+// fromLua converts the integral numbers of the given value to int64, Lua does not distinguish them from floats.
+func fromLua(v any) any {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	case []any:
+		for i := range v {
+			v[i] = fromLua(v[i])
+		}
+	case map[string]any:
+		for k := range v {
+			v[k] = fromLua(v[k])
+		}
+	}
+	return v
+}
+
+//
+//
+// Errors
+//
+//
+
+var (
+	reLuaFrame    = regexp.MustCompile(`^\s+(.+?):(\d+): in (.+)$`)
+	reLuaLocation = regexp.MustCompile(`^(.+?):(\d+): `)
+)
+
+// luaTraceback is a message handler adding the traceback to the error.
+func luaTraceback(l *lua.State) int {
+	msg, ok := l.ToString(1)
+	if !ok {
+		msg, _ = lua.ToStringMeta(l, 1)
+	}
+
+	lua.Traceback(l, l, msg, 1)
+	return 1
+}
+
+// luaError converts an error with its traceback, produced by luaTraceback, to an Error.
+// The cause is the Go error raised by a function, nil if none.
+func luaError(err error, traceback string, cause error) error {
+	msg, stack, _ := strings.Cut(traceback, "\nstack traceback:")
+
+	e := &Error{
+		Message: reLuaLocation.ReplaceAllString(msg, ""),
+		Err:     err,
+	}
+
+	// Syntax errors do not have any traceback.
+	if m := reLuaLocation.FindStringSubmatch(msg); m != nil && stack == "" {
+		n, _ := strconv.Atoi(m[2])
+		e.Frames = append(e.Frames, Frame{File: m[1], Line: n})
+	}
+
+	for _, line := range strings.Split(stack, "\n") {
+		m := reLuaFrame.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		n, _ := strconv.Atoi(m[2])
+		e.Frames = append(e.Frames, Frame{File: m[1], Line: n, Function: m[3]})
+	}
+
+	if cause != nil {
+		e.Causes = errorChain(cause)
+	}
+
+	return e
+}
@@ -0,0 +1,257 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/parser"
+	"github.com/d5/tengo/v2/stdlib"
+	"github.com/mdouchement/ldt/pkg/tengolib"
+)
+
+// Tengo is the engine of the Tengo scripts.
+var Tengo = &TengoEngine{}
+
+// A TengoEngine compiles the scripts with the stdlib and tengolib modules.
+type TengoEngine struct {
+	mu      sync.Mutex
+	modules []Module
+}
+
+// Name implements Engine.
+func (e *TengoEngine) Name() string {
+	return "tengo"
+}
+
+// Extensions implements Engine.
+func (e *TengoEngine) Extensions() []string {
+	return []string{".tgo", ".tengo"}
+}
+
+// Expose implements Engine, the module is imported with `import("<name>")`.
+func (e *TengoEngine) Expose(module Module) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.modules = append(e.modules, module)
+}
+
+// Libraries implements Engine.
+func (e *TengoEngine) Libraries() map[string][]string {
+	modules := e.Modules()
+
+	names := append(stdlib.AllModuleNames(), tengolib.AllModuleNames()...)
+	e.mu.Lock()
+	for _, module := range e.modules {
+		names = append(names, module.Name)
+	}
+	e.mu.Unlock()
+
+	libraries := make(map[string][]string, len(names))
+	for _, name := range names {
+		m := modules.GetBuiltinModule(name)
+		if _, ok := libraries[name]; ok || m == nil {
+			continue
+		}
+
+		libraries[name] = make([]string, 0, len(m.Attrs))
+		for attr := range m.Attrs {
+			libraries[name] = append(libraries[name], attr)
+		}
+	}
+
+	return libraries
+}
+
+// Compile implements Engine.
+func (e *TengoEngine) Compile(src Source) (Program, error) {
+	bytecode, err := e.Bytecode(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTengoProgram(bytecode), nil
+}
+
+// Modules returns the stdlib modules merged with the tengolib modules and the exposed modules.
+func (e *TengoEngine) Modules() *tengo.ModuleMap {
+	modules := stdlib.GetModuleMap(stdlib.AllModuleNames()...)
+	tengolib.MergeModule(modules, tengolib.AllModuleNames()...)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, module := range e.modules {
+		attrs := make(map[string]tengo.Object, len(module.Functions))
+		for name, fn := range module.Functions {
+			attrs[name] = &tengo.UserFunction{Name: name, Value: tengoFunction(fn)}
+		}
+
+		if m := modules.GetBuiltinModule(module.Name); m != nil {
+			for name, attr := range attrs {
+				m.Attrs[name] = attr
+			}
+			continue
+		}
+		modules.AddBuiltinModule(module.Name, attrs)
+	}
+
+	return modules
+}
+
+// Bytecode compiles the given source to bytecode.
+func (e *TengoEngine) Bytecode(src Source) (*tengo.Bytecode, error) {
+	fileset := parser.NewFileSet()
+	file := fileset.AddFile(src.Name, -1, len(src.Code))
+
+	p := parser.NewParser(file, src.Code, nil)
+	f, err := p.ParseFile()
+	if err != nil {
+		return nil, tengoError(err)
+	}
+
+	c := tengo.NewCompiler(file, tengolib.NewSymbolTable(), nil, e.Modules(), nil)
+	c.EnableFileImport(true)
+	c.SetImportDir(src.ImportDir)
+
+	if err := c.Compile(f); err != nil {
+		return nil, tengoError(err)
+	}
+
+	bytecode := c.Bytecode()
+	bytecode.RemoveDuplicates()
+	return bytecode, nil
+}
+
+// A TengoProgram is a compiled Tengo script.
+type TengoProgram struct {
+	Bytecode *tengo.Bytecode
+}
+
+// NewTengoProgram returns the program of the given bytecode (e.g. decoded from a .tgoc file).
+func NewTengoProgram(bytecode *tengo.Bytecode) *TengoProgram {
+	return &TengoProgram{Bytecode: bytecode}
+}
+
+// Run implements Program, args are given to the script as `os.args()` (the process arguments).
+func (p *TengoProgram) Run(ctx context.Context, args []string) error {
+	os.Args = append([]string{os.Args[0]}, args...)
+
+	globals := make([]tengo.Object, tengo.GlobalsSize)
+	tengolib.SetRuntime(p.Bytecode, globals)
+	vm := tengo.NewVM(p.Bytecode, globals, -1)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- vm.Run()
+	}()
+
+	select {
+	case err := <-done:
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if err != nil {
+			return tengoError(err)
+		}
+		return nil
+	case <-ctx.Done():
+		vm.Abort()
+		<-done
+		return context.Cause(ctx)
+	}
+}
+
+// tengoFunction adapts the given function to Tengo.
+func tengoFunction(fn Function) tengo.CallableFunc {
+	return func(args ...tengo.Object) (tengo.Object, error) {
+		values := make([]any, len(args))
+		for i, arg := range args {
+			values[i] = tengo.ToInterface(arg)
+		}
+
+		result, err := fn(values...)
+		if err != nil {
+			return tengolib.WrapError(err), nil
+		}
+
+		return tengo.FromInterface(result)
+	}
+}
+
+//
+//
+// Errors
+//
+//
+
+var reTengoFrame = regexp.MustCompile(`^\s*at (.+):(\d+):(\d+)$`)
+
+// tengoError converts the compilation and runtime errors of Tengo to an Error.
+func tengoError(err error) error {
+	var cerr *tengo.CompilerError
+	if errors.As(err, &cerr) {
+		pos := cerr.FileSet.Position(cerr.Node.Pos())
+		return &Error{
+			Message: cerr.Err.Error(),
+			Frames:  []Frame{{File: pos.Filename, Line: pos.Line, Column: pos.Column}},
+			Err:     err,
+		}
+	}
+
+	var list parser.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		return &Error{
+			Message: list[0].Msg,
+			Frames:  []Frame{{File: list[0].Pos.Filename, Line: list[0].Pos.Line, Column: list[0].Pos.Column}},
+			Err:     err,
+		}
+	}
+
+	msg, stack, _ := strings.Cut(err.Error(), "\n")
+	if !strings.HasPrefix(msg, "Runtime Error: ") {
+		return err
+	}
+
+	e := &Error{
+		Err: err,
+	}
+
+	// Errors raised in functions called back by builtins are wrapped once more.
+	for strings.HasPrefix(msg, "Runtime Error: ") {
+		msg = strings.TrimPrefix(msg, "Runtime Error: ")
+	}
+	e.Message = msg
+
+	for _, line := range strings.Split(stack, "\n") {
+		m := reTengoFrame.FindStringSubmatch(line)
+		if m == nil {
+			continue // e.g. `at -` for the entrypoint of a callback
+		}
+
+		n, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		e.Frames = append(e.Frames, Frame{File: m[1], Line: n, Column: col})
+	}
+
+	// The Go-side error chain below the runtime errors.
+	cause := err
+	for {
+		inner := errors.Unwrap(cause)
+		if inner == nil || !strings.HasPrefix(cause.Error(), "Runtime Error: ") {
+			break
+		}
+		cause = inner
+	}
+	if cause != err {
+		e.Causes = errorChain(cause)
+	}
+
+	return e
+}
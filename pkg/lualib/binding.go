@@ -0,0 +1,203 @@
+package lualib
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/Shopify/go-lua"
+	"github.com/Shopify/goluago/util"
+	"github.com/mdouchement/ldt/pkg/binding"
+)
+
+var (
+	fileModeType = reflect.TypeOf(binding.FileMode(0))
+	callbackType = reflect.TypeOf(binding.Callback(nil))
+)
+
+// bindings returns the functions of the given binding module adapted to Lua.
+func bindings(module string) []lua.RegistryFunction {
+	var library []lua.RegistryFunction
+	for _, fn := range binding.Modules[module] {
+		library = append(library, bind(fn))
+	}
+	return library
+}
+
+// bind adapts the given function to Lua, the errors are raised.
+func bind(f binding.Function) lua.RegistryFunction {
+	return bindAt(f, 1)
+}
+
+// bindAt adapts the given function whose arguments start at the given index, 2 for a method called with `:`.
+func bindAt(f binding.Function, first int) lua.RegistryFunction {
+	fn, params, _ := f.Signature()
+	variadic := fn.Type().IsVariadic()
+
+	return lua.RegistryFunction{
+		Name: f.Name,
+		Function: func(l *lua.State) int {
+			var in []reflect.Value
+			for i, t := range params {
+				if variadic && i == len(params)-1 {
+					for j := i + first; j <= l.Top(); j++ {
+						in = append(in, luaArgument(l, j, t.Elem()))
+					}
+					break
+				}
+				in = append(in, luaArgument(l, i+first, t))
+			}
+
+			out, err := binding.Call(fn, in)
+			if err != nil {
+				raise(l, err)
+			}
+
+			for _, v := range out {
+				switch v := v.Interface().(type) {
+				case binding.Results:
+					// The results of a callback are already on the stack.
+					return v.Values.(int)
+				case *binding.Object:
+					pushObject(l, v)
+				case []byte:
+					l.PushString(string(v))
+				default:
+					util.DeepPush(l, v)
+				}
+			}
+			return len(out)
+		},
+	}
+}
+
+// pushObject pushes the given object as a table whose methods are called with `:`.
+func pushObject(l *lua.State, o *binding.Object) {
+	l.NewTable()
+	for k, v := range o.Fields {
+		util.DeepPush(l, v)
+		l.SetField(-2, k)
+	}
+
+	for _, m := range o.Methods {
+		l.PushGoFunction(bindAt(m, 2).Function)
+		l.SetField(-2, m.Name)
+	}
+}
+
+// luaArgument returns the argument at the given index converted to the given type.
+func luaArgument(l *lua.State, index int, t reflect.Type) reflect.Value {
+	switch {
+	case t == callbackType:
+		lua.CheckType(l, index, lua.TypeFunction)
+		return reflect.ValueOf(binding.Callback(func() (binding.Results, error) {
+			top := l.Top()
+			l.PushValue(index)
+			l.Call(0, lua.MultipleReturns)
+			return binding.Results{Values: l.Top() - top}, nil
+		}))
+	case t == fileModeType:
+		// There is no octal literal in Lua, so 755 is read as 0755.
+		mode, err := strconv.ParseUint(strconv.Itoa(lua.CheckInteger(l, index)), 8, 32)
+		if err != nil {
+			lua.ArgumentError(l, index, err.Error())
+		}
+		return reflect.ValueOf(binding.FileMode(mode))
+	case t.Kind() == reflect.String:
+		return reflect.ValueOf(lua.CheckString(l, index)).Convert(t)
+	case t.Kind() == reflect.Int, t.Kind() == reflect.Int64:
+		return reflect.ValueOf(lua.CheckInteger(l, index)).Convert(t)
+	case t.Kind() == reflect.Float64:
+		return reflect.ValueOf(lua.CheckNumber(l, index)).Convert(t)
+	case t.Kind() == reflect.Bool:
+		return reflect.ValueOf(l.ToBoolean(index)).Convert(t)
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		lua.CheckType(l, index, lua.TypeTable)
+		s := reflect.MakeSlice(t, 0, lua.LengthEx(l, index))
+		for i := 1; i <= lua.LengthEx(l, index); i++ {
+			l.RawGetInt(index, i)
+			s = reflect.Append(s, reflect.ValueOf(lua.CheckString(l, -1)))
+			l.Pop(1)
+		}
+		return s
+	default:
+		value := pullValue(l, index, 0)
+
+		v := reflect.New(t).Elem()
+		if value != nil {
+			if !reflect.TypeOf(value).AssignableTo(t) {
+				lua.ArgumentError(l, index, t.String()+" expected")
+			}
+			v.Set(reflect.ValueOf(value))
+		}
+		return v
+	}
+}
+
+// pullValue returns the value at the given index as nil, bool, int64, float64, string, []any or map[string]any.
+// Tables with the keys 1..n are arrays, integral numbers are int64.
+func pullValue(l *lua.State, index, depth int) any {
+	index = l.AbsIndex(index)
+
+	switch l.TypeOf(index) {
+	case lua.TypeBoolean:
+		return l.ToBoolean(index)
+	case lua.TypeNumber:
+		n, _ := l.ToNumber(index)
+		if n == math.Trunc(n) && math.Abs(n) < 1<<53 {
+			return int64(n)
+		}
+		return n
+	case lua.TypeString:
+		s, _ := l.ToString(index)
+		return s
+	case lua.TypeTable:
+		if depth > 32 {
+			lua.Errorf(l, "table nested too deeply")
+		}
+
+		n := lua.LengthEx(l, index)
+		values := map[string]any{}
+		array := make([]any, n)
+		count := 0
+
+		l.PushNil()
+		for l.Next(index) {
+			count++
+			if i, ok := arrayIndex(l, -2, n); ok {
+				array[i-1] = pullValue(l, -1, depth+1)
+			} else {
+				l.PushValue(-2) // ToString converts numbers in place, which would break Next
+				key, _ := l.ToString(-1)
+				l.Pop(1)
+				values[key] = pullValue(l, -1, depth+1)
+			}
+			l.Pop(1)
+		}
+
+		if n > 0 && count == n {
+			return array
+		}
+		for i, v := range array {
+			if v != nil {
+				values[strconv.Itoa(i+1)] = v
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// arrayIndex returns the integer key at the given index if it is in 1..n.
+func arrayIndex(l *lua.State, index, n int) (int, bool) {
+	if l.TypeOf(index) != lua.TypeNumber {
+		return 0, false
+	}
+
+	k, _ := l.ToNumber(index)
+	if k != math.Trunc(k) || k < 1 || k > float64(n) {
+		return 0, false
+	}
+	return int(k), true
+}
@@ -0,0 +1,125 @@
+package lualib
+
+import (
+	"strings"
+
+	"github.com/Shopify/go-lua"
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+// errorsKey is the registry field holding the Go errors raised by the functions, indexed by their Lua message.
+// Go-Lua only raises strings when there is no message handler (e.g. pcall), so the context of an error
+// is looked up through its message rather than carried by a table.
+const errorsKey = "lualib.errors"
+
+// raise raises the given error as a Lua error.
+func raise(l *lua.State, err error) {
+	lua.Where(l, 1)
+	where, _ := l.ToString(-1)
+	l.Pop(1)
+	msg := where + err.Error()
+
+	lua.SubTable(l, lua.RegistryIndex, errorsKey)
+	l.PushUserData(err)
+	l.SetField(-2, msg)
+	l.Pop(1)
+
+	l.PushString(msg)
+	l.Error()
+}
+
+// RaisedError returns the Go error raised with the given Lua message, nil if none.
+// The error is forgotten once returned.
+func RaisedError(l *lua.State, msg string) error {
+	lua.SubTable(l, lua.RegistryIndex, errorsKey)
+	defer l.Pop(1)
+
+	l.Field(-1, msg)
+	err, _ := l.ToUserData(-1).(error)
+	l.Pop(1)
+
+	if err != nil {
+		l.PushNil()
+		l.SetField(-2, msg)
+	}
+	return err
+}
+
+// withTry adds to the given library a non-raising variant of each function, prefixed by `try_`.
+// On failure, a try_ function returns nil and an error table:
+//
+//	local content, err = ioutil.try_read_file("~/.gitconfig")
+//	if err and err.kind == "not_exist" then ... end
+//
+// The functions already having a try_ variant in the library are kept as is.
+func withTry(library []lua.RegistryFunction) []lua.RegistryFunction {
+	names := make(map[string]bool, len(library))
+	for _, fn := range library {
+		names[fn.Name] = true
+	}
+
+	functions := append([]lua.RegistryFunction{}, library...)
+	for _, fn := range library {
+		if strings.HasPrefix(fn.Name, "try_") || names["try_"+fn.Name] {
+			continue
+		}
+
+		functions = append(functions, lua.RegistryFunction{
+			Name:     "try_" + fn.Name,
+			Function: try(fn.Function),
+		})
+	}
+
+	return functions
+}
+
+func try(fn lua.Function) lua.Function {
+	return func(l *lua.State) int {
+		l.PushGoFunction(fn)
+		l.Insert(1)
+		if err := l.ProtectedCall(l.Top()-1, lua.MultipleReturns, 0); err != nil {
+			if primitive.Interrupted() != nil {
+				l.Error() // Halts and interruptions are not catchable.
+			}
+
+			msg, _ := l.ToString(-1)
+			ctx := primitive.MessageContext(msg)
+			if err := RaisedError(l, msg); err != nil {
+				ctx = primitive.ContextOf(err)
+			}
+
+			l.SetTop(0)
+			l.PushNil()
+			pushError(l, ctx)
+			return 2
+		}
+
+		return l.Top()
+	}
+}
+
+// pushError pushes the given error context as a table onto the stack.
+// The table is converted to its message by tostring().
+func pushError(l *lua.State, ctx *primitive.ErrorContext) {
+	l.NewTable()
+	for k, v := range map[string]string{"kind": ctx.Kind, "message": ctx.Message, "op": ctx.Op, "path": ctx.Path, "url": ctx.URL, "cause": ctx.Cause} {
+		if v != "" {
+			l.PushString(v)
+			l.SetField(-2, k)
+		}
+	}
+
+	if ctx.Status != 0 {
+		l.PushInteger(ctx.Status)
+		l.SetField(-2, "status")
+	}
+
+	if lua.NewMetaTable(l, "lualib.error") {
+		l.PushGoFunction(func(l *lua.State) int {
+			l.Field(1, "message")
+			return 1
+		})
+		l.SetField(-2, "__tostring")
+	}
+	l.SetMetaTable(-2)
+}
@@ -56,7 +56,7 @@ var filepathLibrary = []lua.RegistryFunction{
 			// Cleanup separator
 			path, err := upathex.ExpandTilde(path)
 			if err != nil {
-				lua.Errorf(l, err.Error())
+				raise(l, err)
 			}
 
 			// Replace environment variables by their values.
@@ -65,22 +65,7 @@ var filepathLibrary = []lua.RegistryFunction{
 			// Compute absolute path.
 			path, err = filepath.Abs(path)
 			if err != nil {
-				lua.Errorf(l, err.Error())
-			}
-
-			l.PushString(path)
-			return 1
-		},
-	},
-	{
-		// filepath.expand_tilde("~/.go/bin/")
-		Name: "expand_tilde",
-		Function: func(l *lua.State) int {
-			path := lua.CheckString(l, 1)
-
-			path, err := upathex.ExpandTilde(path)
-			if err != nil {
-				lua.Errorf(l, err.Error())
+				raise(l, err)
 			}
 
 			l.PushString(path)
@@ -110,7 +95,7 @@ var filepathLibrary = []lua.RegistryFunction{
 					continue
 				}
 
-				lua.Errorf(l, err.Error())
+				raise(l, err)
 			}
 
 			lua.Errorf(l, "not found")
@@ -162,12 +147,61 @@ var filepathLibrary = []lua.RegistryFunction{
 			return util.DeepPush(l, matches)
 		},
 	},
+	{
+		// filepath.walk("~/project", function(path, info) if info.name == ".git" then return filepath.skip_dir end end)
+		// => info is {name, mtime, size, mode, directory}, the walk is stopped when fn raises an error.
+		Name: "walk",
+		Function: func(l *lua.State) int {
+			root := lua.CheckString(l, 1)
+			lua.CheckType(l, 2, lua.TypeFunction)
+
+			err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+
+				l.PushValue(2)
+				l.PushString(path)
+				util.DeepPush(l, map[string]any{
+					"name":      info.Name(),
+					"mtime":     info.ModTime().Unix(),
+					"size":      info.Size(),
+					"mode":      int64(info.Mode()),
+					"directory": info.IsDir(),
+				})
+				l.Call(2, 1)
+
+				ret, _ := l.ToString(-1)
+				l.Pop(1)
+				if ret == skipDir && d.IsDir() {
+					return filepath.SkipDir
+				}
+
+				return nil
+			})
+			if err != nil {
+				raise(l, err)
+			}
+
+			return 0
+		},
+	},
 }
 
+// skipDir is returned by a walk function to skip the current directory.
+const skipDir = "skip this directory"
+
 // FilePathOpen opens the filepath library. Usually passed to Require (local filepath = require "lualib/filepath").
 func FilePathOpen(l *lua.State) {
 	open := func(l *lua.State) int {
-		lua.NewLibrary(l, filepathLibrary)
+		lua.NewLibrary(l, Libraries["lualib/filepath"])
+		l.PushString(skipDir)
+		l.SetField(-2, "skip_dir")
 		return 1
 	}
 	lua.Require(l, "lualib/filepath", open, false)
@@ -14,7 +14,7 @@ var ioutilLibrary = []lua.RegistryFunction{
 		Function: func(l *lua.State) int {
 			data, err := ioutil.ReadFile(lua.CheckString(l, 1))
 			if err != nil {
-				lua.Errorf(l, err.Error())
+				raise(l, err)
 			}
 
 			l.PushString(string(data))
@@ -27,7 +27,7 @@ var ioutilLibrary = []lua.RegistryFunction{
 // Deprecated: Use os instead
 func IOUtilOpen(l *lua.State) {
 	open := func(l *lua.State) int {
-		lua.NewLibrary(l, ioutilLibrary)
+		lua.NewLibrary(l, Libraries["lualib/ioutil"])
 		return 1
 	}
 	lua.Require(l, "lualib/ioutil", open, false)
@@ -0,0 +1,74 @@
+package lualib
+
+import (
+	"github.com/Shopify/go-lua"
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+// exitHooksKey is the registry field holding the functions registered by ldt.on_exit.
+const exitHooksKey = "lualib/ldt.on_exit"
+
+var ldtLibrary = []lua.RegistryFunction{
+	{
+		// ldt.halt("Unsupported platform")
+		Name: "halt",
+		Function: func(l *lua.State) int {
+			err := primitive.Halt(lua.CheckString(l, 1))
+
+			// Raise the error again on each instruction so it cannot be swallowed by a pcall.
+			lua.SetDebugHook(l, func(l *lua.State, _ lua.Debug) {
+				lua.Errorf(l, "%s", err.Error())
+			}, lua.MaskCount, 1)
+
+			lua.Errorf(l, "%s", err.Error())
+			return 0
+		},
+	},
+	{
+		// ldt.on_exit(function() os.rm_rf(tmp) end)
+		// => the function is called when the script completes, fails or is halted.
+		Name: "on_exit",
+		Function: func(l *lua.State) int {
+			lua.CheckType(l, 1, lua.TypeFunction)
+
+			l.Field(lua.RegistryIndex, exitHooksKey)
+			if l.IsNil(-1) {
+				l.Pop(1)
+				l.NewTable()
+				l.PushValue(-1)
+				l.SetField(lua.RegistryIndex, exitHooksKey)
+			}
+
+			i := lua.LengthEx(l, -1) + 1
+			l.PushValue(1)
+			l.RawSetInt(-2, i)
+			l.Pop(1)
+
+			primitive.OnExit(func() error {
+				// The script is over, it must not be aborted anymore.
+				lua.SetDebugHook(l, nil, 0, 0)
+
+				l.Field(lua.RegistryIndex, exitHooksKey)
+				l.RawGetInt(-1, i)
+				l.Remove(-2)
+				if err := l.ProtectedCall(0, 0, 0); err != nil {
+					l.Pop(1)
+					return err
+				}
+				return nil
+			})
+
+			return 0
+		},
+	},
+}
+
+// LDTOpen opens the ldt library. Usually passed to Require (local ldt = require "lualib/ldt").
+func LDTOpen(l *lua.State) {
+	open := func(l *lua.State) int {
+		lua.NewLibrary(l, ldtLibrary)
+		return 1
+	}
+	lua.Require(l, "lualib/ldt", open, false)
+	l.Pop(1)
+}
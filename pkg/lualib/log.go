@@ -0,0 +1,13 @@
+package lualib
+
+import "github.com/Shopify/go-lua"
+
+// LogOpen opens the log library. Usually passed to Require (local log = require "lualib/log").
+func LogOpen(l *lua.State) {
+	open := func(l *lua.State) int {
+		lua.NewLibrary(l, Libraries["lualib/log"])
+		return 1
+	}
+	lua.Require(l, "lualib/log", open, false)
+	l.Pop(1)
+}
@@ -2,6 +2,23 @@ package lualib
 
 import "github.com/Shopify/go-lua"
 
+// Libraries are all lualib libraries indexed by their require name.
+// Each function raising errors has a non-raising variant prefixed by `try_` (see withTry).
+// The functions shared with tengolib are declared in the binding package.
+var Libraries = map[string][]lua.RegistryFunction{
+	"lualib/filepath": withTry(append(filepathLibrary, bindings("filepath")...)),
+	"lualib/http":     withTry(bindings("http")),
+	"lualib/ioutil":   withTry(ioutilLibrary),
+	"lualib/ldt":      ldtLibrary,
+	"lualib/log":      bindings("log"),
+	"lualib/os":       withTry(append(osLibrary, bindings("os")...)),
+	"lualib/prompt":   withTry(bindings("prompt")),
+	"lualib/strings":  withTry(bindings("strings")),
+	"lualib/sudo":     withTry(bindings("sudo")),
+	"lualib/times":    withTry(bindings("times")),
+	"lualib/yaml":     withTry(yamlLibrary),
+}
+
 // Open opens all lualib libraries.
 func Open(l *lua.State) {
 	OSOpen(l)
@@ -10,4 +27,9 @@ func Open(l *lua.State) {
 	IOUtilOpen(l)
 	YAMLOpen(l)
 	StringsOpen(l)
+	TimesOpen(l)
+	SudoOpen(l)
+	PromptOpen(l)
+	LogOpen(l)
+	LDTOpen(l)
 }
@@ -0,0 +1,13 @@
+package lualib
+
+import "github.com/Shopify/go-lua"
+
+// PromptOpen opens the prompt library. Usually passed to Require (local prompt = require "lualib/prompt").
+func PromptOpen(l *lua.State) {
+	open := func(l *lua.State) int {
+		lua.NewLibrary(l, Libraries["lualib/prompt"])
+		return 1
+	}
+	lua.Require(l, "lualib/prompt", open, false)
+	l.Pop(1)
+}
@@ -0,0 +1,13 @@
+package lualib
+
+import "github.com/Shopify/go-lua"
+
+// SudoOpen opens the sudo library. Usually passed to Require (local sudo = require "lualib/sudo").
+func SudoOpen(l *lua.State) {
+	open := func(l *lua.State) int {
+		lua.NewLibrary(l, Libraries["lualib/sudo"])
+		return 1
+	}
+	lua.Require(l, "lualib/sudo", open, false)
+	l.Pop(1)
+}
@@ -0,0 +1,13 @@
+package lualib
+
+import "github.com/Shopify/go-lua"
+
+// TimesOpen opens the times library. Usually passed to Require (local times = require "lualib/times").
+func TimesOpen(l *lua.State) {
+	open := func(l *lua.State) int {
+		lua.NewLibrary(l, Libraries["lualib/times"])
+		return 1
+	}
+	lua.Require(l, "lualib/times", open, false)
+	l.Pop(1)
+}
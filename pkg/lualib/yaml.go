@@ -13,12 +13,12 @@ var yamlLibrary = []lua.RegistryFunction{
 		Function: func(l *lua.State) int {
 			table, err := util.PullTable(l, 1)
 			if err != nil {
-				lua.Errorf(l, err.Error())
+				raise(l, err)
 			}
 
 			payload, err := yaml.Marshal(table)
 			if err != nil {
-				lua.Errorf(l, err.Error())
+				raise(l, err)
 			}
 
 			l.PushString(string(payload))
@@ -32,7 +32,7 @@ var yamlLibrary = []lua.RegistryFunction{
 			payload := lua.CheckString(l, 1)
 			var output any
 			if err := yaml.Unmarshal([]byte(payload), &output); err != nil {
-				lua.Errorf(l, err.Error())
+				raise(l, err)
 			}
 
 			return util.DeepPush(l, output)
@@ -43,7 +43,7 @@ var yamlLibrary = []lua.RegistryFunction{
 // YAMLOpen opens the yaml library. Usually passed to Require (local yaml = require "lualib/yaml").
 func YAMLOpen(l *lua.State) {
 	open := func(l *lua.State) int {
-		lua.NewLibrary(l, yamlLibrary)
+		lua.NewLibrary(l, Libraries["lualib/yaml"])
 		return 1
 	}
 	lua.Require(l, "lualib/yaml", open, false)
@@ -0,0 +1,23 @@
+package primitive
+
+import (
+	"errors"
+	"io/fs"
+)
+
+var assets fs.FS
+
+// SetAssets sets the filesystem holding the data files of the running action.
+// It is the action's directory or the content of a bundle.
+func SetAssets(fsys fs.FS) {
+	assets = fsys
+}
+
+// ReadAsset reads the named data file of the running action.
+func ReadAsset(name string) ([]byte, error) {
+	if assets == nil {
+		return nil, errors.New("no assets available")
+	}
+
+	return fs.ReadFile(assets, name)
+}
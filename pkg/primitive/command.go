@@ -0,0 +1,188 @@
+package primitive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Output modes of a command.
+const (
+	OutputCapture = "capture" // Kept in the result
+	OutputStream  = "stream"  // Written to ldt's stdout and stderr
+	OutputTee     = "tee"     // Both
+	OutputQuiet   = "quiet"   // Kept in the result, a spinner is shown and the log is written to stderr on failure
+)
+
+// A Command describes a command run by Exec.
+type Command struct {
+	Name     string
+	Args     []string
+	Dir      string
+	Env      map[string]string // Merged with ldt's environment unless ClearEnv is set
+	ClearEnv bool
+	Stdin    io.Reader
+	Timeout  time.Duration // No timeout if zero
+	Output   string        // OutputCapture by default
+
+	// Streamed lines are prefixed by Prefix and the time if Timestamps is set.
+	Prefix     string
+	Timestamps bool
+}
+
+// A CommandResult is the outcome of a command which has been started.
+type CommandResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int // -1 if the command has been killed by a signal
+	Duration time.Duration
+	Signal   string // Name of the signal which killed the command, empty if none
+	TimedOut bool
+}
+
+// Exec runs the given command and returns its result.
+// A non-zero exit status is not an error, only failing to start or to wait for the command is.
+func Exec(c Command) (*CommandResult, error) {
+	cmd := exec.Command(c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	cmd.Env = c.Environ()
+	cmd.Stdin = c.Stdin
+
+	var stdout, stderr, log bytes.Buffer
+	lines, err := c.setOutput(cmd, &stdout, &stderr, &log)
+	if err != nil {
+		return nil, err
+	}
+
+	flush := func() {
+		for _, w := range lines {
+			w.Flush()
+		}
+	}
+	defer flush()
+
+	start := time.Now()
+	if err := StartCommand(cmd); err != nil {
+		return nil, err
+	}
+
+	stop := func(bool) {}
+	if c.Output == OutputQuiet {
+		stop = Spinner(c.String())
+	}
+
+	var timedout atomic.Bool
+	if c.Timeout > 0 {
+		timer := time.AfterFunc(c.Timeout, func() {
+			timedout.Store(true)
+			killProcessGroup(cmd)
+		})
+		defer timer.Stop()
+	}
+
+	err = WaitCommand(cmd)
+	flush()
+	stop(err == nil)
+	if err != nil && c.Output == OutputQuiet {
+		os.Stderr.Write(log.Bytes())
+	}
+
+	return commandResult(cmd, err, stdout.Bytes(), stderr.Bytes(), start, timedout.Load())
+}
+
+// setOutput sets the stdout and stderr of cmd according to the output mode, the captured outputs are written to
+// stdout and stderr and the log of the quiet mode to log. It returns the line writers which must be flushed.
+func (c Command) setOutput(cmd *exec.Cmd, stdout, stderr, log io.Writer) ([]*lineWriter, error) {
+	var lines []*lineWriter
+	switch c.Output {
+	case "", OutputCapture:
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+	case OutputStream, OutputTee:
+		var streamout, streamerr io.Writer = os.Stdout, os.Stderr
+		if c.Prefix != "" || c.Timestamps {
+			lines = c.lineWriters(os.Stdout, os.Stderr)
+			streamout, streamerr = lines[0], lines[1]
+		}
+
+		cmd.Stdout, cmd.Stderr = streamout, streamerr
+		if c.Output == OutputTee {
+			cmd.Stdout, cmd.Stderr = io.MultiWriter(stdout, streamout), io.MultiWriter(stderr, streamerr)
+		}
+	case OutputQuiet:
+		// The log keeps stdout and stderr in order, like a terminal would show them.
+		lines = c.lineWriters(log, log)
+		cmd.Stdout, cmd.Stderr = io.MultiWriter(stdout, lines[0]), io.MultiWriter(stderr, lines[1])
+	default:
+		return nil, fmt.Errorf("unsupported output mode: %s", c.Output)
+	}
+
+	return lines, nil
+}
+
+// commandResult returns the result of the given command once WaitCommand returned err.
+func commandResult(cmd *exec.Cmd, err error, stdout, stderr []byte, start time.Time, timedout bool) (*CommandResult, error) {
+	result := &CommandResult{
+		Stdout:   stdout,
+		Stderr:   stderr,
+		Duration: time.Since(start),
+		TimedOut: timedout,
+	}
+
+	var exiterr *exec.ExitError
+	if err != nil && !errors.As(err, &exiterr) {
+		return nil, err
+	}
+
+	result.ExitCode = cmd.ProcessState.ExitCode()
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		result.Signal = status.Signal().String()
+	}
+
+	return result, nil
+}
+
+// lineWriters returns the line writers of stdout and stderr written to the given writers.
+func (c Command) lineWriters(stdout, stderr io.Writer) []*lineWriter {
+	mu := new(sync.Mutex)
+	return []*lineWriter{
+		{mu: mu, w: stdout, prefix: c.Prefix, timestamps: c.Timestamps},
+		{mu: mu, w: stderr, prefix: c.Prefix, timestamps: c.Timestamps},
+	}
+}
+
+// Environ returns the environment of the command.
+func (c Command) Environ() []string {
+	if len(c.Env) == 0 && !c.ClearEnv {
+		return nil // Inherited
+	}
+
+	env := map[string]string{}
+	if !c.ClearEnv {
+		env = ParseEnviron(os.Environ())
+	}
+	for k, v := range c.Env {
+		env[k] = v
+	}
+
+	environ := make([]string, 0, len(env))
+	for k, v := range env {
+		environ = append(environ, k+"="+v)
+	}
+	sort.Strings(environ)
+
+	return environ
+}
+
+// String returns the command line.
+func (c Command) String() string {
+	return strings.TrimSpace(c.Name + " " + strings.Join(c.Args, " "))
+}
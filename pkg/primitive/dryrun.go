@@ -0,0 +1,37 @@
+package primitive
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+var dryrun bool
+
+// SetDryRun enables or disables the dry-run mode.
+// In dry-run mode, side-effecting operations are only logged.
+func SetDryRun(enabled bool) {
+	dryrun = enabled
+}
+
+// IsDryRun returns true if the dry-run mode is enabled.
+func IsDryRun() bool {
+	return dryrun
+}
+
+// DryRun logs the given operation and returns true if the dry-run mode is enabled.
+// The operation is logged whatever the log level, it is the output of a dry-run.
+// The caller must skip the operation when true is returned.
+func DryRun(op string, args ...any) bool {
+	if !dryrun {
+		return false
+	}
+
+	params := make([]string, 0, len(args))
+	for _, arg := range args {
+		params = append(params, fmt.Sprint(arg))
+	}
+
+	logAlways(slog.LevelInfo, "[dry-run] "+op+" "+strings.Join(params, " "))
+	return true
+}
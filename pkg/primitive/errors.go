@@ -0,0 +1,140 @@
+package primitive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// List of the error kinds exposed to the scripts.
+const (
+	ErrorKindNotExist         = "not_exist"
+	ErrorKindPermission       = "permission"
+	ErrorKindExist            = "exists"
+	ErrorKindTimeout          = "timeout"
+	ErrorKindHTTPStatus       = "http_status"
+	ErrorKindChecksumMismatch = "checksum_mismatch"
+	ErrorKindExitStatus       = "exit_status"
+	ErrorKindOther            = "other"
+)
+
+// An HTTPStatusError is returned when a server does not respond with the expected status.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("%s: bad response status: %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// A ChecksumMismatchError is returned when the checksum of a file is not the expected one.
+type ChecksumMismatchError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s: checksum mismatch: expected %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+// An ErrorContext describes an error for the scripts.
+type ErrorContext struct {
+	Kind    string
+	Message string
+	Op      string
+	Path    string
+	URL     string
+	Status  int // HTTP status or exit code
+	Cause   string
+}
+
+// ContextOf returns the context of the given error.
+func ContextOf(err error) *ErrorContext {
+	ctx := &ErrorContext{
+		Kind:    ErrorKindOf(err),
+		Message: err.Error(),
+	}
+
+	var perr *fs.PathError
+	var lerr *os.LinkError
+	var uerr *url.Error
+	var herr *HTTPStatusError
+	var cerr *ChecksumMismatchError
+	var eerr *exec.ExitError
+	switch {
+	case errors.As(err, &perr):
+		ctx.Op, ctx.Path = perr.Op, perr.Path
+	case errors.As(err, &lerr):
+		ctx.Op, ctx.Path = lerr.Op, lerr.New
+	case errors.As(err, &uerr):
+		ctx.Op, ctx.URL = strings.ToLower(uerr.Op), uerr.URL
+	case errors.As(err, &herr):
+		ctx.URL, ctx.Status = herr.URL, herr.StatusCode
+	case errors.As(err, &cerr):
+		ctx.Path = cerr.Path
+	case errors.As(err, &eerr):
+		ctx.Status = eerr.ExitCode()
+	}
+
+	if cause := errors.Unwrap(err); cause != nil {
+		ctx.Cause = cause.Error()
+	}
+
+	return ctx
+}
+
+// ErrorKindOf returns the kind of the given error.
+func ErrorKindOf(err error) string {
+	var herr *HTTPStatusError
+	var cerr *ChecksumMismatchError
+	var eerr *exec.ExitError
+	var terr interface{ Timeout() bool }
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrorKindNotExist
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorKindPermission
+	case errors.Is(err, fs.ErrExist):
+		return ErrorKindExist
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &terr) && terr.Timeout():
+		return ErrorKindTimeout
+	case errors.As(err, &herr):
+		return ErrorKindHTTPStatus
+	case errors.As(err, &cerr):
+		return ErrorKindChecksumMismatch
+	case errors.As(err, &eerr):
+		return ErrorKindExitStatus
+	}
+
+	return ErrorKindOther
+}
+
+// MessageContext guesses the context of an error only known by its message (e.g. raised by a third-party library).
+func MessageContext(msg string) *ErrorContext {
+	ctx := &ErrorContext{
+		Kind:    ErrorKindOther,
+		Message: msg,
+	}
+
+	switch {
+	case strings.HasSuffix(msg, syscall.ENOENT.Error()):
+		ctx.Kind = ErrorKindNotExist
+	case strings.HasSuffix(msg, syscall.EACCES.Error()), strings.HasSuffix(msg, syscall.EPERM.Error()):
+		ctx.Kind = ErrorKindPermission
+	case strings.HasSuffix(msg, syscall.EEXIST.Error()):
+		ctx.Kind = ErrorKindExist
+	case strings.HasSuffix(msg, syscall.ETIMEDOUT.Error()), strings.HasSuffix(msg, "i/o timeout"):
+		ctx.Kind = ErrorKindTimeout
+	}
+
+	return ctx
+}
@@ -0,0 +1,62 @@
+package primitive
+
+import (
+	"errors"
+	"fmt"
+)
+
+// An ExitError requests ldt to exit with the given code once the exit hooks have run.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+var (
+	exitHooks []func() error
+	exiting   bool
+)
+
+// OnExit registers a hook run when the run completes, fails, is halted or interrupted.
+// Hooks are run in the reverse order of their registration.
+func OnExit(fn func() error) {
+	exitHooks = append(exitHooks, fn)
+}
+
+// IsExiting returns true while the exit hooks are run.
+func IsExiting() bool {
+	return exiting
+}
+
+// RunExitHooks runs and unregisters all the exit hooks.
+// Commands can be started by the hooks even if the run has been interrupted.
+func RunExitHooks() error {
+	exiting = true
+	defer func() {
+		exiting = false
+	}()
+
+	var errs []error
+	for len(exitHooks) > 0 {
+		fn := exitHooks[len(exitHooks)-1]
+		exitHooks = exitHooks[:len(exitHooks)-1]
+
+		if err := fn(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Halt logs the given message as an error and interrupts the run.
+// The returned error must be propagated in order to stop the script.
+func Halt(msg string) error {
+	logger.Error(msg)
+
+	err := &ExitError{Code: 1}
+	Interrupt(err)
+	return err
+}
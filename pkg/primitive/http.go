@@ -0,0 +1,44 @@
+package primitive
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Download fetches the given URL to dst, a progress bar is displayed on stderr if progress is true.
+// dst is only replaced once the download is complete.
+func Download(url, dst string, progress bool) error {
+	req, err := http.NewRequestWithContext(Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPStatusError{URL: url, StatusCode: resp.StatusCode}
+	}
+
+	f, err := CreatePartial(dst)
+	if err != nil {
+		return err
+	}
+	defer DiscardPartial(f)
+
+	var r io.Reader = resp.Body
+	if progress {
+		defer time.Sleep(500 * time.Millisecond) // just to avoid glitches.
+		r = WithProgressBar(resp.ContentLength, r)
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+
+	return CommitPartial(f, dst)
+}
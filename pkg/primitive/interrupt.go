@@ -0,0 +1,156 @@
+package primitive
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+var (
+	interruption, interrupt = context.WithCancelCause(context.Background())
+
+	mu        sync.Mutex
+	processes = map[*exec.Cmd]bool{}
+	partials  = map[*os.File]bool{}
+)
+
+// A TimeoutError is the cause of the interruption of a run exceeding its timeout.
+// It wraps context.DeadlineExceeded so its kind is ErrorKindTimeout, unlike an interruption by a signal.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout exceeded (%s)", e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Context returns the context of the run, it is done once the run is interrupted.
+func Context() context.Context {
+	return interruption
+}
+
+// Interrupted returns the cause of the interruption, nil if the run is not interrupted.
+func Interrupted() error {
+	return context.Cause(interruption)
+}
+
+// Interrupt aborts the run: the started commands are killed with their children
+// and the partial files are removed.
+func Interrupt(cause error) {
+	interrupt(cause)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for cmd := range processes {
+		killProcessGroup(cmd)
+	}
+
+	for f := range partials {
+		f.Close()
+		os.Remove(f.Name())
+		delete(partials, f)
+	}
+}
+
+// StartCommand starts the given command in its own process group so it can be killed with its children.
+// Commands reading the terminal stay in ldt's process group in order to be able to prompt.
+func StartCommand(cmd *exec.Cmd) error {
+	if err := Interrupted(); err != nil && !exiting {
+		return err
+	}
+
+	if cmd.Stdin != os.Stdin {
+		setProcessGroup(cmd)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	processes[cmd] = true
+
+	return nil
+}
+
+// WaitCommand waits for the given command started with StartCommand.
+func WaitCommand(cmd *exec.Cmd) error {
+	err := cmd.Wait()
+
+	mu.Lock()
+	delete(processes, cmd)
+	mu.Unlock()
+
+	if cause := Interrupted(); cause != nil && !exiting {
+		return cause
+	}
+	return err
+}
+
+// RunCommand starts the given command and waits for it to complete.
+func RunCommand(cmd *exec.Cmd) error {
+	if err := StartCommand(cmd); err != nil {
+		return err
+	}
+
+	return WaitCommand(cmd)
+}
+
+// CreatePartial creates the `.part` file of the given filename.
+// It is removed on interruption or by DiscardPartial if CommitPartial has not been called.
+func CreatePartial(filename string) (*os.File, error) {
+	f, err := os.Create(filename + ".part")
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	partials[f] = true
+	mu.Unlock()
+
+	return f, nil
+}
+
+// CommitPartial renames the given partial file to its final filename.
+func CommitPartial(f *os.File, filename string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !partials[f] {
+		return os.ErrClosed
+	}
+	delete(partials, f)
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+// DiscardPartial removes the given partial file if it has not been committed.
+func DiscardPartial(f *os.File) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !partials[f] {
+		return
+	}
+	delete(partials, f)
+
+	f.Close()
+	os.Remove(f.Name())
+}
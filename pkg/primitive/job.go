@@ -0,0 +1,180 @@
+package primitive
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// JobStopTimeout is the time given to a leftover job to stop after SIGTERM before it is killed.
+var JobStopTimeout = 5 * time.Second
+
+// A Job is a command running in the background.
+// Unless it is detached, it is stopped when the run exits.
+//
+// A zero Job has never been started (e.g. in dry-run mode): it is not running and its result is empty.
+type Job struct {
+	command  Command
+	cmd      *exec.Cmd
+	stdout   syncBuffer
+	stderr   syncBuffer
+	done     chan struct{}
+	result   *CommandResult
+	err      error
+	detached atomic.Bool
+}
+
+// Spawn starts the given command in the background.
+// Its output is captured, streamed or both (the quiet mode is not supported).
+func Spawn(c Command) (*Job, error) {
+	if c.Output == OutputQuiet {
+		return nil, errors.New("the quiet output mode is not supported by jobs")
+	}
+
+	j := &Job{
+		command: c,
+		done:    make(chan struct{}),
+	}
+
+	cmd := exec.Command(c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	cmd.Env = c.Environ()
+	cmd.Stdin = c.Stdin
+	j.cmd = cmd
+
+	lines, err := c.setOutput(cmd, &j.stdout, &j.stderr, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	if err := StartCommand(cmd); err != nil {
+		return nil, err
+	}
+
+	var timedout atomic.Bool
+	var timer *time.Timer
+	if c.Timeout > 0 {
+		timer = time.AfterFunc(c.Timeout, func() {
+			timedout.Store(true)
+			killProcessGroup(cmd)
+		})
+	}
+
+	go func() {
+		defer close(j.done)
+
+		err := WaitCommand(cmd)
+		if timer != nil {
+			timer.Stop()
+		}
+		for _, w := range lines {
+			w.Flush()
+		}
+
+		j.result, j.err = commandResult(cmd, err, j.stdout.Bytes(), j.stderr.Bytes(), start, timedout.Load())
+	}()
+
+	OnExit(j.stop)
+
+	return j, nil
+}
+
+// Pid returns the process ID of the job, 0 if it has never been started.
+func (j *Job) Pid() int {
+	if j.cmd == nil {
+		return 0
+	}
+	return j.cmd.Process.Pid
+}
+
+// String returns the command line of the job.
+func (j *Job) String() string {
+	return j.command.String()
+}
+
+// Running returns true until the job exits.
+func (j *Job) Running() bool {
+	if j.done == nil {
+		return false
+	}
+
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Wait waits for the job to exit and returns its result.
+// A non-zero exit status is not an error, only failing to wait for the command is.
+func (j *Job) Wait() (*CommandResult, error) {
+	if j.done == nil {
+		return &CommandResult{}, nil
+	}
+
+	<-j.done
+	return j.result, j.err
+}
+
+// Kill sends the given signal to the job and its children, it does nothing if the job has exited.
+func (j *Job) Kill(sig os.Signal) error {
+	if !j.Running() {
+		return nil
+	}
+
+	err := signalProcessGroup(j.cmd, sig)
+	if errors.Is(err, os.ErrProcessDone) || errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+	return err
+}
+
+// Stdout returns the output captured so far.
+func (j *Job) Stdout() []byte {
+	return j.stdout.Bytes()
+}
+
+// Stderr returns the error output captured so far.
+func (j *Job) Stderr() []byte {
+	return j.stderr.Bytes()
+}
+
+// Detach lets the job run after ldt exits, it is neither stopped by the exit hooks nor killed on interruption.
+// A captured output is no longer read once ldt has exited, so a detached job should stream its output or
+// redirect it itself.
+func (j *Job) Detach() {
+	if j.cmd == nil {
+		return
+	}
+
+	j.detached.Store(true)
+
+	mu.Lock()
+	delete(processes, j.cmd)
+	mu.Unlock()
+}
+
+// stop terminates a leftover job: SIGTERM first, then SIGKILL after JobStopTimeout.
+func (j *Job) stop() error {
+	if j.detached.Load() || !j.Running() {
+		return nil
+	}
+
+	if err := j.Kill(syscall.SIGTERM); err != nil {
+		killProcessGroup(j.cmd)
+	}
+
+	select {
+	case <-j.done:
+	case <-time.After(JobStopTimeout):
+		killProcessGroup(j.cmd)
+		<-j.done
+	}
+
+	return nil
+}
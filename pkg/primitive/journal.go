@@ -0,0 +1,384 @@
+package primitive
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/mdouchement/ldt/pkg/primitive/archive"
+)
+
+// A JournalEntry describes the state of a path before it has been modified by an operation.
+type JournalEntry struct {
+	Op     string      `json:"op"`
+	Path   string      `json:"path"`
+	Target string      `json:"target,omitempty"` // Destination of a move
+	Exist  bool        `json:"exist"`
+	Backup string      `json:"backup,omitempty"` // Relative to the run directory
+	Link   string      `json:"link,omitempty"`   // Symlink target
+	Mode   fs.FileMode `json:"mode,omitempty"`
+	UID    int         `json:"uid"`
+	GID    int         `json:"gid"`
+	Time   time.Time   `json:"time"`
+}
+
+// A Journal records the changes made during a run in order to be able to rollback them.
+type Journal struct {
+	ID      string
+	dir     string
+	f       *os.File
+	entries int
+}
+
+var journal *Journal
+
+// OpenJournal creates the journal of a new run and makes it the current journal.
+func OpenJournal() (*Journal, error) {
+	id := time.Now().Format("20060102-150405") + "-" + strconv.Itoa(os.Getpid())
+
+	dir, err := RunDir(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(filepath.Join(dir, "backups"), 0700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, "journal.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	journal = &Journal{
+		ID:  id,
+		dir: dir,
+		f:   f,
+	}
+	return journal, nil
+}
+
+// Close closes the journal. The run directory is removed when nothing has been recorded.
+func (j *Journal) Close() error {
+	if journal == j {
+		journal = nil
+	}
+
+	if err := j.f.Close(); err != nil {
+		return err
+	}
+
+	if j.entries == 0 {
+		return os.RemoveAll(j.dir)
+	}
+	return nil
+}
+
+// Track records in the current journal the state of the given path before it gets modified.
+// The content of the path is backed up.
+func Track(op, path string) error {
+	if journal == nil || dryrun {
+		return nil
+	}
+
+	return journal.record(op, path, true)
+}
+
+// TrackAttributes records in the current journal the mode and owner of the given path before they get modified.
+// Chmod and chown follow symlinks, so the attributes of the link's target are recorded.
+func TrackAttributes(op, path string) error {
+	if journal == nil || dryrun {
+		return nil
+	}
+
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	return journal.record(op, path, false)
+}
+
+// TrackCreation records in the current journal the given path if it does not exist yet, so the rollback removes it.
+// An existing path is left as is by the operation (e.g. mkdir_p), it is neither recorded nor backed up.
+func TrackCreation(op, path string) error {
+	if journal == nil || dryrun || Exist(path) {
+		return nil
+	}
+
+	return journal.record(op, path, false)
+}
+
+// TrackMove records in the current journal a move of src to dst.
+// The state of dst must be tracked before with Track.
+func TrackMove(src, dst string) error {
+	if journal == nil || dryrun {
+		return nil
+	}
+
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	dst, err = filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+
+	return journal.write(&JournalEntry{
+		Op:     "mv",
+		Path:   src,
+		Target: dst,
+		Exist:  true,
+		Time:   time.Now(),
+	})
+}
+
+func (j *Journal) record(op, path string, content bool) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	entry := &JournalEntry{
+		Op:   op,
+		Path: path,
+		Time: time.Now(),
+	}
+
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		// Only the top-most created directory needs to be removed on rollback.
+		for {
+			parent := filepath.Dir(entry.Path)
+			if parent == entry.Path || Exist(parent) {
+				break
+			}
+			entry.Path = parent
+		}
+
+		return j.write(entry)
+	}
+	if err != nil {
+		return err
+	}
+
+	entry.Exist = true
+	entry.Mode = info.Mode()
+	entry.UID, entry.GID = fileOwner(info)
+
+	switch {
+	case !content:
+	case info.Mode()&fs.ModeSymlink != 0:
+		entry.Link, err = os.Readlink(path)
+		if err != nil {
+			return err
+		}
+	case info.IsDir():
+		entry.Backup = filepath.Join("backups", fmt.Sprintf("%d.tar", j.entries))
+		if err = backupDir(path, filepath.Join(j.dir, entry.Backup)); err != nil {
+			return fmt.Errorf("backup %s: %w", path, err)
+		}
+	default:
+		entry.Backup = filepath.Join("backups", strconv.Itoa(j.entries))
+		if err = Copy(path, filepath.Join(j.dir, entry.Backup)); err != nil {
+			return fmt.Errorf("backup %s: %w", path, err)
+		}
+	}
+
+	return j.write(entry)
+}
+
+func (j *Journal) write(entry *JournalEntry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err = j.f.Write(append(payload, '\n')); err != nil {
+		return err
+	}
+
+	j.entries++
+	return j.f.Sync()
+}
+
+// Rollback restores, in reverse order, the state recorded in the journal of the given run.
+func Rollback(id string) error {
+	dir, err := RunDir(id)
+	if err != nil {
+		return err
+	}
+
+	entries, err := ReadJournal(id)
+	if err != nil {
+		return err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err = restore(dir, entries[i]); err != nil {
+			return fmt.Errorf("%s %s: %w", entries[i].Op, entries[i].Path, err)
+		}
+	}
+
+	return nil
+}
+
+// ReadJournal returns the entries recorded in the journal of the given run.
+func ReadJournal(id string) ([]*JournalEntry, error) {
+	dir, err := RunDir(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, "journal.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []*JournalEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// ListRuns returns the identifiers of the recorded runs.
+func ListRuns() ([]string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "runs", "*", "journal.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, filepath.Base(filepath.Dir(match)))
+	}
+	return ids, nil
+}
+
+// RunDir returns the directory where the journal of the given run is stored.
+func RunDir(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) {
+		return "", fmt.Errorf("invalid run id: %q", id)
+	}
+
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "runs", id), nil
+}
+
+func restore(dir string, entry *JournalEntry) error {
+	if entry.Target != "" {
+		if !Exist(entry.Target) && Exist(entry.Path) {
+			return nil // Already restored by a previous rollback
+		}
+		return os.Rename(entry.Target, entry.Path)
+	}
+
+	if !entry.Exist {
+		return os.RemoveAll(entry.Path)
+	}
+
+	switch {
+	case entry.Link != "":
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return err
+		}
+		return os.Symlink(entry.Link, entry.Path)
+	case entry.Backup != "" && entry.Mode.IsDir():
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return err
+		}
+		if err := restoreDir(filepath.Join(dir, entry.Backup), filepath.Dir(entry.Path)); err != nil {
+			return err
+		}
+	case entry.Backup != "":
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return err
+		}
+		if err := Copy(filepath.Join(dir, entry.Backup), entry.Path); err != nil {
+			return err
+		}
+	}
+
+	// Chmod follows symlinks and a link has no permissions of its own (0777), only its owner is restored.
+	if entry.Mode&fs.ModeSymlink == 0 {
+		if err := os.Chmod(entry.Path, entry.Mode.Perm()); err != nil {
+			return err
+		}
+	}
+
+	uid, gid := fileOwnerOf(entry.Path)
+	if uid == entry.UID && gid == entry.GID {
+		return nil
+	}
+	return os.Lchown(entry.Path, entry.UID, entry.GID)
+}
+
+func backupDir(root, name string) error {
+	files, err := archive.FilesFromDisk(root, archive.FilesFromDiskOptions{})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	codec := archive.NewTarWriter(f)
+	if err = codec.Archives(files); err != nil {
+		return err
+	}
+
+	if err = codec.Close(); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+func restoreDir(name, root string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	codec := archive.NewTarReader(f)
+	if err = codec.Extract(archive.FileToDiskHandler(root)); err != nil {
+		return err
+	}
+
+	return codec.Check()
+}
+
+func fileOwnerOf(path string) (uid, gid int) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return -1, -1
+	}
+
+	return fileOwner(info)
+}
@@ -0,0 +1,189 @@
+package primitive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Log formats.
+const (
+	LogFormatText = "text" // Human readable, colourised on a terminal
+	LogFormatJSON = "json" // One JSON object per line
+)
+
+var (
+	logLevel  = new(slog.LevelVar)
+	logFormat = LogFormatText
+	logger    = slog.New(newTextHandler(os.Stderr, logLevel))
+)
+
+// Logger returns the logger of ldt and the scripts, it writes on stderr.
+func Logger() *slog.Logger {
+	return logger
+}
+
+// SetLogger configures the logger with the given level (debug, info, warn or error) and format (text or json).
+func SetLogger(level, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level: %s", level)
+	}
+	logLevel.Set(l)
+
+	switch format {
+	case LogFormatText:
+		logger = slog.New(newTextHandler(os.Stderr, logLevel))
+	case LogFormatJSON:
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+	default:
+		return fmt.Errorf("invalid log format: %s", format)
+	}
+	logFormat = format
+
+	return nil
+}
+
+// LogFormat returns the format of the logs.
+func LogFormat() string {
+	return logFormat
+}
+
+// Log logs the given message with the given fields sorted by name.
+func Log(level slog.Level, msg string, fields map[string]any) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slog.Any(k, fields[k]))
+	}
+
+	logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// logAlways logs the given message whatever the log level (e.g. the dry-run operations).
+func logAlways(level slog.Level, msg string) {
+	r := slog.NewRecord(time.Now(), level, msg, 0)
+	logger.Handler().Handle(context.Background(), r)
+}
+
+// A textHandler writes `LEVEL message key=value ...` lines, the level is coloured on a terminal.
+type textHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	level  slog.Leveler
+	color  bool
+	attrs  []slog.Attr
+	prefix string // Of the attributes' keys, from the groups
+}
+
+func newTextHandler(w io.Writer, level slog.Leveler) *textHandler {
+	h := &textHandler{
+		mu:    new(sync.Mutex),
+		w:     w,
+		level: level,
+	}
+	if f, ok := w.(*os.File); ok {
+		h.color = term.IsTerminal(int(f.Fd()))
+	}
+	return h
+}
+
+var levelColors = map[slog.Level]string{
+	slog.LevelDebug: "\033[2m",  // Dim
+	slog.LevelInfo:  "\033[34m", // Blue
+	slog.LevelWarn:  "\033[33m", // Yellow
+	slog.LevelError: "\033[31m", // Red
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+
+	level := fmt.Sprintf("%-5s", r.Level.String())
+	if h.color {
+		level = levelColors[r.Level] + level + "\033[0m"
+	}
+	b.WriteString(level)
+	b.WriteString(" ")
+	b.WriteString(r.Message)
+
+	for _, a := range h.attrs {
+		writeAttr(&b, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.prefix, a)
+		return true
+	})
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = slices.Clone(h.attrs)
+	for _, a := range attrs {
+		a.Key = h.prefix + a.Key
+		clone.attrs = append(clone.attrs, a)
+	}
+	return &clone
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+func writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			writeAttr(b, prefix+a.Key+".", ga)
+		}
+		return
+	}
+
+	var v string
+	switch a.Value.Kind() {
+	case slog.KindDuration:
+		v = a.Value.Duration().String()
+	case slog.KindTime:
+		v = a.Value.Time().Format(time.RFC3339)
+	default:
+		v = fmt.Sprint(a.Value.Any())
+	}
+	if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+		v = strconv.Quote(v)
+	}
+
+	b.WriteString(" ")
+	b.WriteString(prefix + a.Key)
+	b.WriteString("=")
+	b.WriteString(v)
+}
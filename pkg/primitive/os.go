@@ -79,14 +79,44 @@ func MkdirAll(p string) {
 
 // Exist returns if a file exists ot not.
 func Exist(p string) bool {
+	exist, err := CheckExist(p)
+	return exist || err != nil // ignoring error
+}
+
+// CheckExist is like Exist but returns the errors not related to the existence of the path (e.g. permission denied).
+func CheckExist(p string) (bool, error) {
 	_, err := os.Stat(p)
 	if err == nil {
-		return true
+		return true, nil
 	}
 	if os.IsNotExist(err) {
-		return false
+		return false, nil
+	}
+	return false, err
+}
+
+// StateDir returns the directory where ldt stores its state ($XDG_STATE_HOME/ldt).
+func StateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "ldt"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
 	}
-	return true // ignoring error
+
+	return filepath.Join(home, ".local", "state", "ldt"), nil
+}
+
+// CacheDir returns the directory where ldt stores its cache ($XDG_CACHE_HOME/ldt).
+func CacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "ldt"), nil
 }
 
 // ParseEnviron parses to a map the os.Environ().
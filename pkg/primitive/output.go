@@ -0,0 +1,64 @@
+package primitive
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// A lineWriter writes each complete line with a prefix and optionally a timestamp.
+// The writers of a command share their mutex so stdout and stderr lines are not interleaved.
+type lineWriter struct {
+	mu         *sync.Mutex
+	w          io.Writer
+	prefix     string
+	timestamps bool
+	buf        []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// Flush writes the last line if it is not terminated.
+func (w *lineWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	line := append(w.buf, '\n')
+	w.buf = nil
+	return w.writeLine(line)
+}
+
+func (w *lineWriter) writeLine(line []byte) error {
+	var b bytes.Buffer
+	if w.timestamps {
+		b.WriteString(time.Now().Format("15:04:05.000 "))
+	}
+	b.WriteString(w.prefix)
+	b.Write(line)
+
+	_, err := w.w.Write(b.Bytes())
+	return err
+}
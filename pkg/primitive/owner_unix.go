@@ -0,0 +1,17 @@
+//go:build !windows
+
+package primitive
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func fileOwner(info fs.FileInfo) (uid, gid int) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, -1
+	}
+
+	return int(stat.Uid), int(stat.Gid)
+}
@@ -0,0 +1,7 @@
+package primitive
+
+import "io/fs"
+
+func fileOwner(_ fs.FileInfo) (uid, gid int) {
+	return -1, -1
+}
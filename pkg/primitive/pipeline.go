@@ -0,0 +1,180 @@
+package primitive
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// A Pipeline connects the stdout of each command to the stdin of the next one, like `a | b` in a shell.
+type Pipeline struct {
+	Commands []Command // Their Stdin, Timeout and Output are ignored
+	Stdin    io.Reader // Stdin of the first command
+	Stdout   io.Writer // Stdout of the last command, it is captured if nil
+	Pipefail bool      // The pipeline fails if any command fails, not only the last one
+	Timeout  time.Duration
+}
+
+// A PipelineResult is the outcome of a pipeline.
+type PipelineResult struct {
+	Stdout     []byte
+	Stderr     []byte // Of all the commands
+	ExitCode   int
+	PipeStatus []int // Exit status of each command, like bash's PIPESTATUS
+	Duration   time.Duration
+	TimedOut   bool
+}
+
+// ExecPipeline runs the given pipeline and returns its result.
+// A non-zero exit status is not an error, only failing to start or to wait for a command is.
+func ExecPipeline(p Pipeline) (*PipelineResult, error) {
+	if len(p.Commands) == 0 {
+		return nil, errors.New("empty pipeline")
+	}
+
+	var stdout bytes.Buffer
+	var stderr syncBuffer
+
+	cmds := make([]*exec.Cmd, len(p.Commands))
+	var pipes []*os.File
+	defer func() {
+		for _, f := range pipes {
+			f.Close()
+		}
+	}()
+
+	for i, c := range p.Commands {
+		cmd := exec.Command(c.Name, c.Args...)
+		cmd.Dir = c.Dir
+		cmd.Env = c.Environ()
+		cmd.Stderr = &stderr
+		cmds[i] = cmd
+
+		if i == 0 {
+			cmd.Stdin = p.Stdin
+		} else {
+			r, w, err := os.Pipe()
+			if err != nil {
+				return nil, err
+			}
+			pipes = append(pipes, r, w)
+
+			cmds[i-1].Stdout = w
+			cmd.Stdin = r
+		}
+	}
+
+	last := cmds[len(cmds)-1]
+	last.Stdout = p.Stdout
+	if last.Stdout == nil {
+		last.Stdout = &stdout
+	}
+
+	start := time.Now()
+	for i, cmd := range cmds {
+		if err := StartCommand(cmd); err != nil {
+			for _, started := range cmds[:i] {
+				killProcessGroup(started)
+				WaitCommand(started)
+			}
+			return nil, err
+		}
+	}
+
+	// The pipes are owned by the commands now, the next command sees EOF once the previous one exits.
+	for _, f := range pipes {
+		f.Close()
+	}
+	pipes = nil
+
+	var timedout atomic.Bool
+	if p.Timeout > 0 {
+		timer := time.AfterFunc(p.Timeout, func() {
+			timedout.Store(true)
+			for _, cmd := range cmds {
+				killProcessGroup(cmd)
+			}
+		})
+		defer timer.Stop()
+	}
+
+	result := &PipelineResult{
+		PipeStatus: make([]int, len(cmds)),
+	}
+
+	var failure error
+	for i, cmd := range cmds {
+		err := WaitCommand(cmd)
+
+		var exiterr *exec.ExitError
+		if err != nil && !errors.As(err, &exiterr) && failure == nil {
+			failure = err
+		}
+
+		if cmd.ProcessState != nil {
+			result.PipeStatus[i] = cmd.ProcessState.ExitCode()
+
+			// Like bash, a command killed by a signal (e.g. SIGPIPE) has the status 128+n.
+			if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				result.PipeStatus[i] = 128 + int(status.Signal())
+			}
+		}
+	}
+	if failure != nil {
+		return nil, failure
+	}
+
+	result.Stdout = stdout.Bytes()
+	result.Stderr = stderr.Bytes()
+	result.Duration = time.Since(start)
+	result.TimedOut = timedout.Load()
+
+	// Like bash, the status is the one of the last command or, with pipefail, the one of the last failed command.
+	result.ExitCode = result.PipeStatus[len(cmds)-1]
+	if p.Pipefail {
+		for _, code := range result.PipeStatus {
+			if code != 0 {
+				result.ExitCode = code
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// String returns the command line of the pipeline.
+func (p Pipeline) String() string {
+	cmds := make([]string, 0, len(p.Commands))
+	for _, c := range p.Commands {
+		cmds = append(cmds, c.String())
+	}
+	return strings.Join(cmds, " | ")
+}
+
+// A syncBuffer is a buffer written by several commands and read while they are running.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+// Bytes returns a copy of the content of the buffer.
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return bytes.Clone(b.buf.Bytes())
+}
@@ -2,10 +2,13 @@ package primitive
 
 import (
 	"io"
+	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/vbauerster/mpb/v8"
 	"github.com/vbauerster/mpb/v8/decor"
+	"golang.org/x/term"
 )
 
 // WithProgressBar attaches a progress bar to the given io.Reader.
@@ -26,3 +29,44 @@ func WithProgressBar(size int64, r io.Reader) io.Reader {
 	)
 	return bar.ProxyReader(r)
 }
+
+// Spinner shows a spinner with the given name and the elapsed time until the returned function is called.
+// It is written on stderr so the output of the script is not mixed with it, nothing is shown when stderr is not a terminal.
+func Spinner(name string) (stop func(ok bool)) {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return func(bool) {}
+	}
+
+	start := time.Now()
+	var status atomic.Pointer[string]
+
+	p := mpb.New(
+		mpb.WithOutput(os.Stderr),
+		mpb.WithWidth(1),
+		mpb.WithRefreshRate(100*time.Millisecond),
+	)
+	bar := p.New(0, mpb.SpinnerStyle(),
+		mpb.PrependDecorators(
+			decor.Name(name+" "),
+		),
+		mpb.AppendDecorators(
+			decor.Any(func(decor.Statistics) string {
+				if s := status.Load(); s != nil {
+					return *s
+				}
+				return time.Since(start).Truncate(time.Second).String()
+			}),
+		),
+	)
+
+	return func(ok bool) {
+		s := "failed after " + time.Since(start).Truncate(time.Millisecond).String()
+		if ok {
+			s = "done in " + time.Since(start).Truncate(time.Millisecond).String()
+		}
+		status.Store(&s)
+
+		bar.SetTotal(-1, true)
+		p.Wait()
+	}
+}
@@ -0,0 +1,289 @@
+package primitive
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// A Prompt describes a question asked to the user.
+// When ldt is not run in a terminal, the answer is read from the environment variable Env,
+// then Default is used, otherwise an error is returned.
+type Prompt struct {
+	Message  string
+	Default  string
+	Env      string
+	Validate *regexp.Regexp // Input answers must match it
+}
+
+var (
+	assumeYes bool
+	stdin     = bufio.NewReader(os.Stdin)
+)
+
+// SetAssumeYes makes Confirm accept without asking.
+func SetAssumeYes(enabled bool) {
+	assumeYes = enabled
+}
+
+// Interactive returns true if the user can be prompted: stdin and stderr are a terminal.
+func Interactive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// Input asks for a line of text, the default is used for an empty answer.
+func Input(p Prompt) (string, error) {
+	if !Interactive() {
+		answer, err := p.fallback()
+		if err != nil {
+			return "", err
+		}
+		if p.Validate != nil && !p.Validate.MatchString(answer) {
+			return "", fmt.Errorf("prompt: %q does not match %s", answer, p.Validate)
+		}
+		return answer, nil
+	}
+
+	for {
+		answer, err := ask(p.Message, p.Default)
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			answer = p.Default
+		}
+
+		if p.Validate == nil || p.Validate.MatchString(answer) {
+			return answer, nil
+		}
+		fmt.Fprintf(os.Stderr, "  invalid answer, it must match %s\n", p.Validate)
+	}
+}
+
+// Password asks for a secret without echoing it.
+func Password(p Prompt) (string, error) {
+	if !Interactive() {
+		return p.fallback()
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: ", p.Message)
+	defer fmt.Fprintln(os.Stderr)
+
+	fd := int(os.Stdin.Fd())
+	state, err := term.GetState(fd)
+	if err != nil {
+		return "", err
+	}
+
+	answer, err := read(func() (string, error) {
+		b, err := term.ReadPassword(fd)
+		return string(b), err
+	})
+	if err != nil {
+		term.Restore(fd, state) // The read may still be pending without echo
+		return "", err
+	}
+	return answer, nil
+}
+
+// Confirm asks a yes/no question, the default ("yes" or "no") is used for an empty answer.
+// It accepts without asking when the --yes flag is given.
+func Confirm(p Prompt) (bool, error) {
+	if assumeYes {
+		return true, nil
+	}
+
+	if !Interactive() {
+		answer, err := p.fallback()
+		if err != nil {
+			return false, err
+		}
+		return parseYes(answer)
+	}
+
+	hint := "y/n"
+	if p.Default != "" {
+		def, err := parseYes(p.Default)
+		if err != nil {
+			return false, err
+		}
+
+		hint = "y/N"
+		if def {
+			hint = "Y/n"
+		}
+	}
+
+	for {
+		answer, err := ask(p.Message+" ["+hint+"]", "")
+		if err != nil {
+			return false, err
+		}
+		if answer == "" {
+			answer = p.Default
+		}
+
+		if ok, err := parseYes(answer); err == nil {
+			return ok, nil
+		}
+		fmt.Fprintln(os.Stderr, "  please answer yes or no")
+	}
+}
+
+// Select asks to choose one of the given choices by number or by name.
+func Select(p Prompt, choices []string) (string, error) {
+	answers, err := selectChoices(p, choices, false)
+	if err != nil {
+		return "", err
+	}
+	return answers[0], nil
+}
+
+// MultiSelect asks to choose any of the given choices by numbers or names separated by commas or spaces.
+// The default is a comma-separated list.
+func MultiSelect(p Prompt, choices []string) ([]string, error) {
+	return selectChoices(p, choices, true)
+}
+
+func selectChoices(p Prompt, choices []string, multiple bool) ([]string, error) {
+	if len(choices) == 0 {
+		return nil, errors.New("prompt: no choices")
+	}
+
+	if !Interactive() {
+		answer, err := p.fallback()
+		if err != nil {
+			return nil, err
+		}
+		return parseChoices(answer, choices, multiple)
+	}
+
+	for i, choice := range choices {
+		fmt.Fprintf(os.Stderr, "  %d) %s\n", i+1, choice)
+	}
+
+	for {
+		answer, err := ask(p.Message, p.Default)
+		if err != nil {
+			return nil, err
+		}
+		if answer == "" {
+			answer = p.Default
+		}
+
+		selected, err := parseChoices(answer, choices, multiple)
+		if err == nil {
+			return selected, nil
+		}
+		fmt.Fprintf(os.Stderr, "  %s\n", strings.TrimPrefix(err.Error(), "prompt: "))
+	}
+}
+
+// parseChoices returns the choices given by number or by name in the answer.
+func parseChoices(answer string, choices []string, multiple bool) ([]string, error) {
+	fields := []string{strings.TrimSpace(answer)}
+	if multiple {
+		fields = strings.FieldsFunc(answer, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+
+	selected := []string{}
+	for _, field := range fields {
+		if field == "" {
+			continue
+		}
+
+		choice := field
+		if n, err := strconv.Atoi(field); err == nil && n >= 1 && n <= len(choices) {
+			choice = choices[n-1]
+		} else if !slices.Contains(choices, field) {
+			return nil, fmt.Errorf("prompt: invalid choice %q", field)
+		}
+
+		if !slices.Contains(selected, choice) {
+			selected = append(selected, choice)
+		}
+	}
+
+	if !multiple && len(selected) == 0 {
+		return nil, errors.New("prompt: a choice is required")
+	}
+	return selected, nil
+}
+
+func parseYes(answer string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes", "true", "1":
+		return true, nil
+	case "n", "no", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("prompt: invalid yes/no answer %q", answer)
+}
+
+// fallback returns the answer of a non-interactive run.
+func (p Prompt) fallback() (string, error) {
+	if p.Env != "" {
+		if v, ok := os.LookupEnv(p.Env); ok {
+			return v, nil
+		}
+	}
+
+	if p.Default != "" {
+		return p.Default, nil
+	}
+
+	if p.Env != "" {
+		return "", fmt.Errorf("prompt: %s: not running in a terminal, set $%s", p.Message, p.Env)
+	}
+	return "", fmt.Errorf("prompt: %s: not running in a terminal", p.Message)
+}
+
+// ask prints the message with its default and reads the answer.
+func ask(message, def string) (string, error) {
+	if def != "" {
+		message += " (" + def + ")"
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", message)
+
+	line, err := read(func() (string, error) {
+		return stdin.ReadString('\n')
+	})
+	if errors.Is(err, io.EOF) && line == "" {
+		return "", errors.New("prompt: no answer (end of input)")
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// read runs the given blocking read, it is aborted when the run is interrupted.
+func read(fn func() (string, error)) (string, error) {
+	type result struct {
+		s   string
+		err error
+	}
+
+	c := make(chan result, 1)
+	go func() {
+		s, err := fn()
+		c <- result{s: s, err: err}
+	}()
+
+	select {
+	case r := <-c:
+		return r.s, r.err
+	case <-Context().Done():
+		fmt.Fprintln(os.Stderr)
+		return "", Interrupted()
+	}
+}
@@ -0,0 +1,203 @@
+package primitive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+	"unicode"
+)
+
+// Statuses of an operation.
+const (
+	StatusChanged   = "changed"
+	StatusUnchanged = "unchanged"
+	StatusFailed    = "failed"
+)
+
+// An Operation is a mutating builtin run during the run.
+type Operation struct {
+	Op       string        `json:"op"`
+	Target   string        `json:"target"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"duration"` // ns
+	Error    string        `json:"error,omitempty"`
+}
+
+// A Report summarizes the operations of a run.
+type Report struct {
+	Changed    int           `json:"changed"`
+	Unchanged  int           `json:"unchanged"`
+	Failed     int           `json:"failed"`
+	Duration   time.Duration `json:"duration"` // ns
+	Operations []Operation   `json:"operations"`
+}
+
+var (
+	reportMu    sync.Mutex
+	reportStart = time.Now()
+	operations  []Operation
+)
+
+// StartReport resets the run report, its duration starts now.
+func StartReport() {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+
+	reportStart = time.Now()
+	operations = nil
+}
+
+// Operate runs the given mutating operation on target, unless unchanged returns true,
+// and records its status and duration in the run report. unchanged is nil when the operation always changes something.
+func Operate(op, target string, unchanged func() bool, fn func() error) error {
+	start := time.Now()
+
+	o := Operation{
+		Op:     op,
+		Target: target,
+		Status: StatusChanged,
+	}
+
+	var err error
+	if unchanged != nil && unchanged() {
+		o.Status = StatusUnchanged
+	} else if err = fn(); err != nil {
+		o.Status = StatusFailed
+		o.Error = err.Error()
+	}
+	o.Duration = time.Since(start)
+
+	reportMu.Lock()
+	operations = append(operations, o)
+	reportMu.Unlock()
+
+	return err
+}
+
+// RunReport returns the report of the operations run so far.
+func RunReport() *Report {
+	reportMu.Lock()
+	defer reportMu.Unlock()
+
+	r := &Report{
+		Duration:   time.Since(reportStart),
+		Operations: append([]Operation{}, operations...),
+	}
+	for _, o := range operations {
+		switch o.Status {
+		case StatusChanged:
+			r.Changed++
+		case StatusUnchanged:
+			r.Unchanged++
+		case StatusFailed:
+			r.Failed++
+		}
+	}
+
+	return r
+}
+
+// WriteSummary writes the counts of the report followed by one line per operation.
+func (r *Report) WriteSummary(w io.Writer) {
+	fmt.Fprintf(w, "Summary: %d changed, %d unchanged, %d failed in %s\n", r.Changed, r.Unchanged, r.Failed, FormatDuration(r.Duration))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, o := range r.Operations {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s", o.Status, o.Op, escape(o.Target), FormatDuration(o.Duration))
+		if o.Error != "" {
+			fmt.Fprintf(tw, "\t%s", escape(o.Error))
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+// escape quotes the given text if it has control characters (e.g. the newline of a command's argument)
+// so it stays on its line of the summary.
+func escape(s string) string {
+	if strings.IndexFunc(s, func(r rune) bool { return !unicode.IsPrint(r) && r != ' ' }) < 0 {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// FormatDuration formats the given duration with a precision depending on its magnitude (e.g. 1.5s, 2m3s).
+func FormatDuration(duration time.Duration) string {
+	switch {
+	case duration > time.Hour:
+		duration = duration.Truncate(time.Second)
+	case duration > time.Minute:
+		duration = duration.Truncate(time.Second)
+	case duration > time.Second:
+		duration = duration.Truncate(time.Millisecond)
+	case duration > time.Millisecond:
+		duration = duration.Truncate(time.Microsecond)
+	}
+
+	return duration.String()
+}
+
+// SameContent returns true if dst exists with the same content as src.
+func SameContent(src, dst string) bool {
+	sinfo, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+	dinfo, err := os.Stat(dst)
+	if err != nil || !dinfo.Mode().IsRegular() || sinfo.Size() != dinfo.Size() {
+		return false
+	}
+
+	s, err := os.ReadFile(src)
+	if err != nil {
+		return false
+	}
+	return SameBytes(dst, s)
+}
+
+// SameBytes returns true if the file exists with the given content.
+func SameBytes(filename string, payload []byte) bool {
+	info, err := os.Stat(filename)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != int64(len(payload)) {
+		return false
+	}
+
+	content, err := os.ReadFile(filename)
+	return err == nil && bytes.Equal(content, payload)
+}
+
+// SameMode returns true if the path exists with the given permissions.
+func SameMode(path string, mode fs.FileMode) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().Perm() == mode.Perm()
+}
+
+// SameOwner returns true if the path exists with the given owner.
+func SameOwner(path string, uid, gid int) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	u, g := fileOwner(info)
+	return u == uid && g == gid
+}
+
+// DirExist returns true if the path is an existing directory.
+func DirExist(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Missing returns true if nothing exists at the path, not even a dangling symlink.
+func Missing(path string) bool {
+	_, err := os.Lstat(path)
+	return os.IsNotExist(err)
+}
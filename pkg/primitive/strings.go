@@ -1,7 +1,10 @@
 package primitive
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"os/exec"
 	"strings"
 )
 
@@ -36,3 +39,122 @@ func LongestCommonPathPrefix(p1, p2 string) string {
 
 	return p1
 }
+
+// ShellQuote returns the given arguments quoted for a POSIX shell and separated by spaces.
+// Arguments made of safe characters are left as is, the other ones are single-quoted.
+func ShellQuote(args ...string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	safe := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("_@%+=:,./-", c) >= 0) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+
+	// Nothing is special between single quotes, a single quote is written as '\''.
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ShellSplit splits the given command line into arguments with the quoting rules of a POSIX shell:
+// single quotes, double quotes and backslash escapes. There is no expansion (variables, globs, ~)
+// and no operator (|, ;, >, ...), they are kept as literal characters.
+func ShellSplit(s string) ([]string, error) {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool // An argument is started, even if it is empty like ''
+		quote   rune // The opening quote, 0 outside quotes
+		escaped bool
+	)
+
+	for _, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+			if c == '\n' {
+				continue // Line continuation
+			}
+			if quote == '"' && !strings.ContainsRune("$`\"\\", c) {
+				arg.WriteRune('\\') // In double quotes, only these characters are escaped
+			}
+			arg.WriteRune(c)
+			inArg = true
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+				continue
+			}
+			arg.WriteRune(c)
+		case c == '\\':
+			escaped = true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+				continue
+			}
+			arg.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(c)
+			inArg = true
+		}
+	}
+
+	switch {
+	case escaped:
+		return nil, errors.New("shell_split: unterminated escape at the end of the command line")
+	case quote != 0:
+		return nil, fmt.Errorf("shell_split: unterminated %c quote", quote)
+	case inArg:
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
+
+// SplitCommand returns the given command, a command given without arguments is split as a command line
+// (e.g. `ls -l "My Documents"`) unless it names a program: an executable path or a name found in $PATH
+// (e.g. `/opt/my tools/run`).
+func SplitCommand(name string, args []string) (string, []string, error) {
+	if len(args) > 0 {
+		return name, args, nil
+	}
+
+	if _, err := exec.LookPath(name); err == nil {
+		return name, nil, nil
+	}
+
+	argv, err := ShellSplit(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(argv) == 0 {
+		return "", nil, errors.New("empty command")
+	}
+
+	return argv[0], argv[1:], nil
+}
@@ -0,0 +1,132 @@
+package primitive
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestShellQuoteRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "safe", args: []string{"git", "commit", "-m", "msg", "a=b", "user@host:/tmp/x,y"}},
+		{name: "spaces", args: []string{"ls", "-l", "My Documents", "  leading and trailing  "}},
+		{name: "empty", args: []string{"printf", "", "%s", ""}},
+		{name: "unicode", args: []string{"echo", "héllo wörld", "日本語", "🚀"}},
+		{name: "newlines", args: []string{"sh", "-c", "echo a\necho b\n", "\n"}},
+		{name: "tabs", args: []string{"a\tb", "\t"}},
+		{name: "single quotes", args: []string{"it's", "'", "''", "'quoted'"}},
+		{name: "double quotes", args: []string{`say "hi"`, `"`}},
+		{name: "backslashes", args: []string{`C:\Users`, `\`, `\\`, `a\'b`}},
+		{name: "shell characters", args: []string{"$HOME", "`id`", "a|b", "a;b", "*.go", "~", "a>b", "!x", "#c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quoted := ShellQuote(tt.args...)
+
+			args, err := ShellSplit(quoted)
+			if err != nil {
+				t.Fatalf("ShellSplit(%q): %v", quoted, err)
+			}
+			if !slices.Equal(args, tt.args) {
+				t.Errorf("ShellSplit(ShellQuote(%q)) = %q via %q", tt.args, args, quoted)
+			}
+		})
+	}
+}
+
+func TestShellSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmdline string
+		args    []string
+		err     string
+	}{
+		{name: "blank", cmdline: " \t\n", args: nil},
+		{name: "words", cmdline: "  ls   -l\t/tmp\n", args: []string{"ls", "-l", "/tmp"}},
+		{name: "double quotes", cmdline: `git commit -m "first commit"`, args: []string{"git", "commit", "-m", "first commit"}},
+		{name: "single quotes", cmdline: `echo 'a "b" $c'`, args: []string{"echo", `a "b" $c`}},
+		{name: "empty quotes", cmdline: `a '' "" b`, args: []string{"a", "", "", "b"}},
+		{name: "adjacent quotes", cmdline: `a'b'"c"d`, args: []string{"abcd"}},
+		{name: "escaped space", cmdline: `cat My\ Documents`, args: []string{"cat", "My Documents"}},
+		{name: "escapes in double quotes", cmdline: `echo "\$HOME \"x\" \\ \n"`, args: []string{"echo", `$HOME "x" \ \n`}},
+		{name: "no escape in single quotes", cmdline: `echo '\n\'`, args: []string{"echo", `\n\`}},
+		{name: "line continuation", cmdline: "make \\\n  all", args: []string{"make", "all"}},
+		{name: "quoted newline", cmdline: "echo 'a\nb'", args: []string{"echo", "a\nb"}},
+		{name: "unicode", cmdline: `echo "héllo wörld" 日本語`, args: []string{"echo", "héllo wörld", "日本語"}},
+		{name: "no operators", cmdline: `a | b; c > d`, args: []string{"a", "|", "b;", "c", ">", "d"}},
+		{name: "unterminated single quote", cmdline: `echo 'abc`, err: "shell_split: unterminated ' quote"},
+		{name: "unterminated double quote", cmdline: `echo "abc`, err: `shell_split: unterminated " quote`},
+		{name: "unterminated quote after escape", cmdline: `echo "a\"`, err: `shell_split: unterminated " quote`},
+		{name: "trailing backslash", cmdline: `echo abc\`, err: "shell_split: unterminated escape at the end of the command line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := ShellSplit(tt.cmdline)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("ShellSplit(%q) error = %v, want %q", tt.cmdline, err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ShellSplit(%q): %v", tt.cmdline, err)
+			}
+			if !slices.Equal(args, tt.args) {
+				t.Errorf("ShellSplit(%q) = %q, want %q", tt.cmdline, args, tt.args)
+			}
+		})
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	program := filepath.Join(t.TempDir(), "my dir", "tool")
+	if err := os.MkdirAll(filepath.Dir(program), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(program, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		command  string
+		args     []string
+		wantName string
+		wantArgs []string
+		err      bool
+	}{
+		{name: "with arguments", command: "git commit", args: []string{"-m", "msg"}, wantName: "git commit", wantArgs: []string{"-m", "msg"}},
+		{name: "command line", command: `git commit -m "first commit"`, wantName: "git", wantArgs: []string{"commit", "-m", "first commit"}},
+		{name: "executable path with spaces", command: program, wantName: program},
+		{name: "quoted executable path", command: ShellQuote(program, "-v"), wantName: program, wantArgs: []string{"-v"}},
+		{name: "program in PATH", command: "sh", wantName: "sh"},
+		{name: "empty", command: " ", err: true},
+		{name: "unterminated quote", command: `echo "abc`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args, err := SplitCommand(tt.command, tt.args)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("SplitCommand(%q) = %q %q, want an error", tt.command, name, args)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("SplitCommand(%q): %v", tt.command, err)
+			}
+			if name != tt.wantName || !slices.Equal(args, tt.wantArgs) {
+				t.Errorf("SplitCommand(%q) = %q %q, want %q %q", tt.command, name, args, tt.wantName, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package primitive
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SudoKeepAlive is the interval at which the sudo credential is refreshed during a run.
+var SudoKeepAlive = time.Minute
+
+var (
+	sudoNonInteractive bool
+
+	sudoOnce sync.Once
+	sudoTool string
+	sudoErr  error
+)
+
+// SetSudoNonInteractive enables or disables the non-interactive mode of Sudo (e.g. for CI).
+// In this mode, the password is never asked so the privileged operations fail unless
+// the credential is already cached or no password is required.
+func SetSudoNonInteractive(enabled bool) {
+	sudoNonInteractive = enabled
+}
+
+// Sudo returns the given command run as root through sudo or doas.
+// The credential is asked once per run and kept alive until the run exits.
+// The command is returned as is when ldt is already run by root.
+func Sudo(c Command) (Command, error) {
+	if os.Geteuid() == 0 {
+		return c, nil
+	}
+
+	sudoOnce.Do(authenticate)
+	if sudoErr != nil {
+		return c, sudoErr
+	}
+
+	var args []string
+	if sudoNonInteractive {
+		args = append(args, "-n")
+	}
+
+	// sudo and doas reset the environment, so the command's variables are given to env.
+	if len(c.Env) > 0 || c.ClearEnv {
+		args = append(args, "env")
+		if c.ClearEnv {
+			args = append(args, "-i")
+		}
+
+		env := make([]string, 0, len(c.Env))
+		for k, v := range c.Env {
+			env = append(env, k+"="+v)
+		}
+		sort.Strings(env)
+		args = append(args, env...)
+	}
+	args = append(args, c.Name)
+	args = append(args, c.Args...)
+
+	c.Name, c.Args = sudoTool, args
+	c.Env, c.ClearEnv = nil, false
+	return c, nil
+}
+
+// authenticate finds sudo or doas and validates the credential, asking the password if needed.
+func authenticate() {
+	for _, tool := range []string{"sudo", "doas"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			sudoTool = tool
+			break
+		}
+	}
+	if sudoTool == "" {
+		sudoErr = errors.New("sudo: neither sudo nor doas is installed")
+		return
+	}
+
+	validate := []string{"-v"} // doas has no way to only validate the credential
+	if sudoTool == "doas" {
+		validate = []string{"true"}
+	}
+	if sudoNonInteractive {
+		validate = append([]string{"-n"}, validate...)
+	}
+
+	// The prompt is written on the terminal, the command stays in ldt's process group with the terminal's stdin.
+	cmd := exec.Command(sudoTool, validate...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := RunCommand(cmd); err != nil {
+		if sudoNonInteractive {
+			sudoErr = fmt.Errorf("%s: a password is required in non-interactive mode: %w", sudoTool, err)
+			return
+		}
+		sudoErr = fmt.Errorf("%s: authentication failed: %w", sudoTool, err)
+		return
+	}
+
+	if sudoTool == "sudo" {
+		keepAlive()
+	}
+}
+
+// keepAlive refreshes the sudo credential until the run exits, so long runs are not prompted again.
+func keepAlive() {
+	done := make(chan struct{})
+	OnExit(func() error {
+		close(done)
+		return nil
+	})
+
+	go func() {
+		ticker := time.NewTicker(SudoKeepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				exec.Command("sudo", "-n", "-v").Run()
+			}
+		}
+	}()
+}
@@ -0,0 +1,202 @@
+package tengolib
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/d5/tengo/v2"
+	"github.com/mdouchement/ldt/pkg/binding"
+)
+
+// withBindings returns the given module merged with the functions of the given binding module.
+func withBindings(module map[string]tengo.Object, name string) map[string]tengo.Object {
+	merged := make(map[string]tengo.Object, len(module))
+	for k, v := range module {
+		merged[k] = v
+	}
+
+	for _, fn := range binding.Modules[name] {
+		merged[fn.Name] = &tengo.UserFunction{Name: fn.Name, Value: bind(fn)}
+	}
+
+	return merged
+}
+
+// bound returns the given function of the given binding module adapted to Tengo, to expose it in another module too.
+// It panics if the function does not exist, bindings are static so it is a programming error.
+func bound(module, name string) *tengo.UserFunction {
+	for _, fn := range binding.Modules[module] {
+		if fn.Name == name {
+			return &tengo.UserFunction{Name: name, Value: bind(fn)}
+		}
+	}
+	panic(fmt.Sprintf("binding: %s.%s does not exist", module, name))
+}
+
+var callbackType = reflect.TypeOf(binding.Callback(nil))
+
+// A callbackError is a runtime error of a callback, it aborts the script instead of being returned as an error value.
+type callbackError struct {
+	err error
+}
+
+func (e callbackError) Error() string {
+	return e.err.Error()
+}
+
+// bind adapts the given function to Tengo, the errors are returned as error values.
+func bind(f binding.Function) tengo.CallableFunc {
+	fn, params, results := f.Signature()
+	variadic := fn.Type().IsVariadic()
+
+	return func(args ...tengo.Object) (tengo.Object, error) {
+		required := len(params)
+		if variadic {
+			required--
+		}
+		if len(args) < required || !variadic && len(args) > required {
+			return nil, tengo.ErrWrongNumArguments
+		}
+
+		var in []reflect.Value
+		for i, arg := range args {
+			t := params[min(i, len(params)-1)]
+			if variadic && i >= len(params)-1 {
+				t = t.Elem()
+			}
+
+			v, err := tengoArgument(arg, i, t)
+			if err != nil {
+				return nil, err
+			}
+			in = append(in, v)
+		}
+
+		out, err := binding.Call(fn, in)
+		if err, ok := err.(callbackError); ok {
+			return nil, err.err // A runtime error of the script
+		}
+		if err != nil {
+			return WrapError(err), nil
+		}
+
+		switch len(results) {
+		case 0:
+			if len(out) == 0 && fn.Type().NumOut() > 0 {
+				return tengo.TrueValue, nil // Like the stdlib functions only returning an error
+			}
+			return tengo.UndefinedValue, nil
+		case 1:
+			return fromValue(out[0].Interface())
+		default:
+			values := make([]tengo.Object, 0, len(out))
+			for _, v := range out {
+				o, err := fromValue(v.Interface())
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, o)
+			}
+			return &tengo.Array{Value: values}, nil
+		}
+	}
+}
+
+// fromValue converts the given result to a Tengo object, an object is a map with its methods.
+func fromValue(v any) (tengo.Object, error) {
+	if r, ok := v.(binding.Results); ok {
+		return r.Values.(tengo.Object), nil
+	}
+
+	o, ok := v.(*binding.Object)
+	if !ok {
+		return tengo.FromInterface(v)
+	}
+
+	m := make(map[string]tengo.Object, len(o.Fields)+len(o.Methods))
+	for k, v := range o.Fields {
+		field, err := tengo.FromInterface(v)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = field
+	}
+
+	for _, fn := range o.Methods {
+		m[fn.Name] = &tengo.UserFunction{Name: fn.Name, Value: bind(fn)}
+	}
+
+	return &tengo.ImmutableMap{Value: m}, nil
+}
+
+// tengoArgument returns the given argument converted to the given type.
+func tengoArgument(arg tengo.Object, i int, t reflect.Type) (reflect.Value, error) {
+	invalid := func(expected string) error {
+		return tengo.ErrInvalidArgumentType{
+			Name:     fmt.Sprintf("args[%d]", i),
+			Expected: expected,
+			Found:    arg.TypeName(),
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Func:
+		if t != callbackType {
+			break
+		}
+
+		if !arg.CanCall() {
+			return reflect.Value{}, invalid("callable")
+		}
+		return reflect.ValueOf(binding.Callback(func() (binding.Results, error) {
+			o, err := Call(arg)
+			if err != nil {
+				return binding.Results{}, callbackError{err: err}
+			}
+			return binding.Results{Values: o}, nil
+		})), nil
+	case reflect.String:
+		s, ok := tengo.ToString(arg)
+		if !ok {
+			return reflect.Value{}, invalid("string(compatible)")
+		}
+		return reflect.ValueOf(s).Convert(t), nil
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		n, ok := tengo.ToInt64(arg)
+		if !ok {
+			return reflect.Value{}, invalid("int(compatible)")
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	case reflect.Float64:
+		f, ok := tengo.ToFloat64(arg)
+		if !ok {
+			return reflect.Value{}, invalid("float(compatible)")
+		}
+		return reflect.ValueOf(f).Convert(t), nil
+	case reflect.Bool:
+		return reflect.ValueOf(!arg.IsFalsy()).Convert(t), nil
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.String {
+			break
+		}
+
+		array, ok := arg.(*tengo.Array)
+		if !ok {
+			return reflect.Value{}, invalid("array")
+		}
+		s, err := StringArray(array.Value, fmt.Sprintf("args[%d]", i))
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(s).Convert(t), nil
+	}
+
+	v := reflect.New(t).Elem()
+	if value := tengo.ToInterface(arg); value != nil {
+		if !reflect.TypeOf(value).AssignableTo(t) {
+			return reflect.Value{}, invalid(t.String())
+		}
+		v.Set(reflect.ValueOf(value))
+	}
+	return v, nil
+}
@@ -0,0 +1,129 @@
+package tengolib
+
+import (
+	"github.com/d5/tengo/v2"
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+// An Error is the error returned by ldt to the scripts, it carries the context of the Go error.
+// Like a Tengo's error, its message is `err.value`; its context is `err.kind`, `err.path`, etc.
+type Error struct {
+	tengo.ObjectImpl
+	Value   tengo.Object
+	Context *primitive.ErrorContext
+}
+
+// TypeName implements tengo.Object.
+func (o *Error) TypeName() string {
+	return "error"
+}
+
+// String implements tengo.Object.
+func (o *Error) String() string {
+	return (&tengo.Error{Value: o.Value}).String()
+}
+
+// IsFalsy implements tengo.Object.
+func (o *Error) IsFalsy() bool {
+	return true
+}
+
+// Copy implements tengo.Object, the copy keeps the context.
+func (o *Error) Copy() tengo.Object {
+	return &Error{Value: o.Value.Copy(), Context: o.Context}
+}
+
+// Equals implements tengo.Object.
+func (o *Error) Equals(x tengo.Object) bool {
+	return o == x
+}
+
+// IndexGet implements tengo.Object, the missing fields of the context are undefined.
+func (o *Error) IndexGet(index tengo.Object) (tengo.Object, error) {
+	key, _ := tengo.ToString(index)
+	if key == "value" {
+		return o.Value, nil
+	}
+
+	if !contextKeys[key] {
+		return nil, tengo.ErrInvalidIndexOnError
+	}
+	return errorContextObject(o.Context, o.Value).IndexGet(index)
+}
+
+// contextKeys are the fields of an error context.
+var contextKeys = map[string]bool{"kind": true, "message": true, "op": true, "path": true, "url": true, "status": true, "cause": true}
+
+// isError is like the builtin is_error but it also knows ldt's errors.
+func isError(args ...tengo.Object) (tengo.Object, error) {
+	if len(args) != 1 {
+		return nil, tengo.ErrWrongNumArguments
+	}
+
+	switch args[0].(type) {
+	case *tengo.Error, *Error:
+		return tengo.TrueValue, nil
+	}
+	return tengo.FalseValue, nil
+}
+
+// lookupErrorContext returns the context of the given error object, nil if it is not an error.
+func lookupErrorContext(o tengo.Object) *primitive.ErrorContext {
+	switch e := o.(type) {
+	case *Error:
+		return e.Context
+	case *tengo.Error:
+		// Not created by WrapError (e.g. Tengo's stdlib or the error builtin).
+		msg, _ := tengo.ToString(e.Value)
+		return primitive.MessageContext(msg)
+	}
+	return nil
+}
+
+// errorValue returns the value of the given error object, nil if it is not an error.
+func errorValue(o tengo.Object) tengo.Object {
+	switch e := o.(type) {
+	case *Error:
+		return e.Value
+	case *tengo.Error:
+		return e.Value
+	}
+	return nil
+}
+
+// errorContextObject returns the given error context as a map.
+func errorContextObject(ctx *primitive.ErrorContext, msg tengo.Object) *tengo.ImmutableMap {
+	m := map[string]tengo.Object{
+		"kind":    &tengo.String{Value: ctx.Kind},
+		"message": msg,
+	}
+
+	for k, v := range map[string]string{"op": ctx.Op, "path": ctx.Path, "url": ctx.URL, "cause": ctx.Cause} {
+		if v != "" {
+			m[k] = &tengo.String{Value: v}
+		}
+	}
+
+	if ctx.Status != 0 {
+		m["status"] = &tengo.Int{Value: int64(ctx.Status)}
+	}
+
+	return &tengo.ImmutableMap{Value: m}
+}
+
+// isKind returns a function telling whether its argument is an error of the given kind.
+func isKind(kind string) *tengo.UserFunction {
+	return &tengo.UserFunction{
+		Name: "is_" + kind,
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) != 1 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+
+			if ctx := lookupErrorContext(args[0]); ctx != nil && ctx.Kind == kind {
+				return tengo.TrueValue, nil
+			}
+			return tengo.FalseValue, nil
+		},
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/mdouchement/upathex"
 )
 
+var skipDir = &tengo.Error{Value: &tengo.String{Value: "skip this directory"}}
+
 var filepathModule = map[string]tengo.Object{
 	// filepath.dirname("pkg/go.mod")
 	"dirname": &tengo.UserFunction{
@@ -56,7 +58,7 @@ var filepathModule = map[string]tengo.Object{
 	// filepath.expand("~/.go/bin/../")
 	"expand": &tengo.UserFunction{
 		Name: "expand",
-		Value: stdlib.FuncASRSE(func(path string) (string, error) {
+		Value: FuncASRSE(func(path string) (string, error) {
 			// Cleanup separator
 			path, err := upathex.ExpandTilde(path)
 			if err != nil {
@@ -95,6 +97,86 @@ var filepathModule = map[string]tengo.Object{
 			return "", errors.New("not found")
 		}),
 	},
+	// filepath.skip_dir is returned by a walk function to skip the current directory.
+	"skip_dir": skipDir,
+	// filepath.walk(root string, fn func(path string, info map) error) => error
+	// => the walk is stopped when fn returns an error, which is returned.
+	"walk": &tengo.UserFunction{
+		Name: "walk",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) != 2 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+
+			root, ok := tengo.ToString(args[0])
+			if !ok {
+				return nil, tengo.ErrInvalidArgumentType{
+					Name:     "first",
+					Expected: "string(compatible)",
+					Found:    args[0].TypeName(),
+				}
+			}
+
+			fn, err := callableArg(args, 1, "second")
+			if err != nil {
+				return nil, err
+			}
+
+			var result tengo.Object = tengo.UndefinedValue
+			var rerr error // VM error raised by fn
+			err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				info, err := d.Info()
+				if err != nil {
+					return err
+				}
+
+				directory := tengo.FalseValue
+				if info.IsDir() {
+					directory = tengo.TrueValue
+				}
+
+				ret, err := Call(fn, &tengo.String{Value: path}, &tengo.ImmutableMap{
+					Value: map[string]tengo.Object{
+						"name":      &tengo.String{Value: info.Name()},
+						"mtime":     &tengo.Time{Value: info.ModTime()},
+						"size":      &tengo.Int{Value: info.Size()},
+						"mode":      &tengo.Int{Value: int64(info.Mode())},
+						"directory": directory,
+					},
+				})
+				if err != nil {
+					rerr = err
+					return err
+				}
+
+				if ret == skipDir {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+
+				if errorValue(ret) != nil {
+					result = ret
+					return fs.SkipAll
+				}
+
+				return nil
+			})
+			if rerr != nil {
+				return nil, rerr
+			}
+			if err != nil {
+				return WrapError(err), nil
+			}
+
+			return result, nil
+		},
+	},
 	// filepath.find("~/.go/bin/", ".*image.*", "(?i).*.(jpg|png)$")
 	"find": &tengo.UserFunction{
 		Name: "find",
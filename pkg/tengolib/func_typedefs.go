@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/d5/tengo/v2"
+	"github.com/mdouchement/ldt/pkg/primitive"
 )
 
 // FuncASR transforms a function of 'func(string)' signature into
@@ -65,15 +66,46 @@ func FuncASSRSE(fn func(string, string) (string, error)) tengo.CallableFunc {
 	}
 }
 
-// FuncASRB transforms a function of 'func(string) bool' signature into
-// CallableFunc type. User function will return 'true' if underlying native
-// function returns nil.
-func FuncASRB(fn func(string) bool) tengo.CallableFunc {
+// The following adapters mirror the stdlib ones but the errors are wrapped with WrapError
+// so that their context is available to the scripts (e.g. ldt.error_info).
+
+// FuncARE transforms a function of 'func() error' signature into CallableFunc
+// type.
+func FuncARE(fn func() error) tengo.CallableFunc {
+	return func(args ...tengo.Object) (ret tengo.Object, err error) {
+		if len(args) != 0 {
+			return nil, tengo.ErrWrongNumArguments
+		}
+		return WrapError(fn()), nil
+	}
+}
+
+// FuncARYE transforms a function of 'func() ([]byte, error)' signature into
+// CallableFunc type.
+func FuncARYE(fn func() ([]byte, error)) tengo.CallableFunc {
+	return func(args ...tengo.Object) (ret tengo.Object, err error) {
+		if len(args) != 0 {
+			return nil, tengo.ErrWrongNumArguments
+		}
+		res, err := fn()
+		if err != nil {
+			return WrapError(err), nil
+		}
+		if len(res) > tengo.MaxBytesLen {
+			return nil, tengo.ErrBytesLimit
+		}
+		return &tengo.Bytes{Value: res}, nil
+	}
+}
+
+// FuncASRSE transforms a function of 'func(string) (string, error)' signature
+// into CallableFunc type. User function will return 'true' if underlying
+// native function returns nil.
+func FuncASRSE(fn func(string) (string, error)) tengo.CallableFunc {
 	return func(args ...tengo.Object) (tengo.Object, error) {
 		if len(args) != 1 {
 			return nil, tengo.ErrWrongNumArguments
 		}
-
 		s1, ok := tengo.ToString(args[0])
 		if !ok {
 			return nil, tengo.ErrInvalidArgumentType{
@@ -82,11 +114,14 @@ func FuncASRB(fn func(string) bool) tengo.CallableFunc {
 				Found:    args[0].TypeName(),
 			}
 		}
-
-		if fn(s1) {
-			return tengo.TrueValue, nil
+		res, err := fn(s1)
+		if err != nil {
+			return WrapError(err), nil
+		}
+		if len(res) > tengo.MaxStringLen {
+			return nil, tengo.ErrStringLimit
 		}
-		return tengo.FalseValue, nil
+		return &tengo.String{Value: res}, nil
 	}
 }
 
@@ -95,7 +130,10 @@ func WrapError(err error) tengo.Object {
 	if err == nil {
 		return tengo.TrueValue
 	}
-	return &tengo.Error{Value: &tengo.String{Value: err.Error()}}
+	return &Error{
+		Value:   &tengo.String{Value: err.Error()},
+		Context: primitive.ContextOf(err),
+	}
 }
 
 // StringArray transforms the given params to a []string.
@@ -115,6 +153,17 @@ func StringArray(arr []tengo.Object, argName string) ([]string, error) {
 	return sarr, nil
 }
 
+// MapValue returns the entries of the given map or immutable map, nil for any other object.
+func MapValue(o tengo.Object) map[string]tengo.Object {
+	switch o := o.(type) {
+	case *tengo.ImmutableMap:
+		return o.Value
+	case *tengo.Map:
+		return o.Value
+	}
+	return nil
+}
+
 // InterfaceArray transforms the given params to a []any.
 func InterfaceArray(args []tengo.Object) []any {
 	arguments := make([]any, len(args))
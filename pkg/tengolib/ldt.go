@@ -2,126 +2,293 @@ package tengolib
 
 import (
 	"fmt"
-	"os"
+	"slices"
+	"time"
 
 	"github.com/d5/tengo/v2"
-	"github.com/d5/tengo/v2/stdlib"
-	"github.com/direnv/direnv/v2/pkg/dotenv"
 	"github.com/mdouchement/ldt/pkg/primitive"
 )
 
-var envcache = make(map[string]*primitive.Env)
-
 var ldtModule = map[string]tengo.Object{
 	// ldt.halt(msg string)
 	"halt": &tengo.UserFunction{
 		Name: "halt",
-		Value: FuncASR(func(msg string) {
-			fmt.Println(msg)
-			os.Exit(1)
-		}),
-	},
-	// ldt.catch(...any) => Catcher
-	"catch": &tengo.UserFunction{
-		Name: "catch",
 		Value: func(args ...tengo.Object) (tengo.Object, error) {
-			var methods *tengo.ImmutableMap
-			methods = &tengo.ImmutableMap{
-				Value: map[string]tengo.Object{
-					// output() => Catcher
-					"output": &tengo.UserFunction{
-						Name: "output",
-						Value: func(nargs ...tengo.Object) (tengo.Object, error) {
-							if len(nargs) != 0 {
-								return nil, tengo.ErrWrongNumArguments
-							}
-
-							for _, arg := range args {
-								fmt.Println(arg)
-							}
-
-							return methods, nil
-						},
-					},
-					// halt() => undefined
-					"halt": &tengo.UserFunction{
-						Name: "halt",
-						Value: func(nargs ...tengo.Object) (tengo.Object, error) {
-							if len(nargs) != 0 {
-								return nil, tengo.ErrWrongNumArguments
-							}
-
-							for _, arg := range args {
-								if _, ok := arg.(*tengo.Error); ok {
-									fmt.Println(arg)
-									os.Exit(1)
-								}
-							}
-
-							return tengo.UndefinedValue, nil
-						},
-					},
-					// first() => error/undefined
-					"first": &tengo.UserFunction{
-						Name: "first",
-						Value: func(nargs ...tengo.Object) (tengo.Object, error) {
-							if len(nargs) != 0 {
-								return nil, tengo.ErrWrongNumArguments
-							}
-
-							for _, arg := range args {
-								if err, ok := arg.(*tengo.Error); ok {
-									return err, nil
-								}
-							}
-
-							return tengo.UndefinedValue, nil
-						},
-					},
-				},
+			if len(args) != 1 {
+				return nil, tengo.ErrWrongNumArguments
 			}
 
-			return methods, nil
+			msg, ok := tengo.ToString(args[0])
+			if !ok {
+				return nil, tengo.ErrInvalidArgumentType{
+					Name:     "first",
+					Expected: "string(compatible)",
+					Found:    args[0].TypeName(),
+				}
+			}
+
+			// Returning an error stops the VM, the exit hooks are run before exiting.
+			return nil, primitive.Halt(msg)
 		},
 	},
-	// ldt.load_direnv(filename string) => error
-	"load_direnv": &tengo.UserFunction{
-		Name: "load_direnv",
-		Value: stdlib.FuncASRE(func(filename string) error {
-			if _, ok := envcache[filename]; ok {
-				return fmt.Errorf("%s already in use", filename)
+	// ldt.on_exit(fn func)
+	// => fn is called when the script completes, fails or is halted.
+	"on_exit": &tengo.UserFunction{
+		Name: "on_exit",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) != 1 {
+				return nil, tengo.ErrWrongNumArguments
 			}
 
-			data, err := os.ReadFile(filename)
+			fn, err := callableArg(args, 0, "first")
 			if err != nil {
+				return nil, err
+			}
+
+			primitive.OnExit(func() error {
+				_, err := Call(fn)
 				return err
+			})
+
+			return tengo.UndefinedValue, nil
+		},
+	},
+	// ldt.with_env(env map, fn func() any) => any
+	// => fn is called with the given environment variables exported, its result is returned.
+	"with_env": &tengo.UserFunction{
+		Name: "with_env",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) != 2 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+
+			values := MapValue(args[0])
+			if values == nil {
+				return nil, tengo.ErrInvalidArgumentType{
+					Name:     "first",
+					Expected: "map",
+					Found:    args[0].TypeName(),
+				}
 			}
 
-			envmap, err := dotenv.Parse(string(data))
+			fn, err := callableArg(args, 1, "second")
 			if err != nil {
-				return err
+				return nil, err
+			}
+
+			envmap := make(map[string]string, len(values))
+			for k, v := range values {
+				s, ok := tengo.ToString(v)
+				if !ok {
+					return nil, tengo.ErrInvalidArgumentType{
+						Name:     fmt.Sprintf("first[%s]", k),
+						Expected: "string(compatible)",
+						Found:    v.TypeName(),
+					}
+				}
+				envmap[k] = s
 			}
 
 			env := primitive.NewEnv(envmap)
 			env.Export()
+			defer env.Restore()
 
-			envcache[filename] = env
-
-			return nil
-		}),
+			return Call(fn)
+		},
 	},
-	// ldt.unload_direnv(filename string) => error
-	"unload_direnv": &tengo.UserFunction{
-		Name: "unload_direnv",
-		Value: stdlib.FuncASRE(func(filename string) error {
-			env, ok := envcache[filename]
+	// ldt.retry(n int, fn func() any, delay int) => any
+	// => fn is called until it does not return an error, at most n times.
+	// => delay is an optional duration between the attempts (e.g. 2 * times.second).
+	"retry": &tengo.UserFunction{
+		Name: "retry",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) != 2 && len(args) != 3 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+
+			n, ok := tengo.ToInt(args[0])
 			if !ok {
-				return fmt.Errorf("%s not loaded", filename)
+				return nil, tengo.ErrInvalidArgumentType{
+					Name:     "first",
+					Expected: "int(compatible)",
+					Found:    args[0].TypeName(),
+				}
+			}
+
+			fn, err := callableArg(args, 1, "second")
+			if err != nil {
+				return nil, err
+			}
+
+			var delay int64
+			if len(args) == 3 {
+				delay, ok = tengo.ToInt64(args[2])
+				if !ok {
+					return nil, tengo.ErrInvalidArgumentType{
+						Name:     "third",
+						Expected: "int(compatible)",
+						Found:    args[2].TypeName(),
+					}
+				}
+			}
+
+			var result tengo.Object = tengo.UndefinedValue
+			for i := 0; i < n; i++ {
+				if i > 0 {
+					select {
+					case <-time.After(time.Duration(delay)):
+					case <-primitive.Context().Done():
+						return nil, primitive.Interrupted()
+					}
+				}
+
+				result, err = Call(fn)
+				if err != nil {
+					return nil, err
+				}
+
+				if errorValue(result) == nil {
+					break
+				}
 			}
 
-			env.Restore()
-			delete(envcache, filename)
-			return nil
-		}),
+			return result, nil
+		},
+	},
+	// ldt.catch(...any) => Catcher
+	"catch": &tengo.UserFunction{
+		Name: "catch",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			return newCatcher(args), nil
+		},
+	},
+	// ldt.error_info(err error) => map/undefined
+	// => {kind: "not_exist", message: "...", op: "open", path: "...", url: "...", status: 404, cause: "..."}
+	"error_info": &tengo.UserFunction{
+		Name: "error_info",
+		Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) != 1 {
+				return nil, tengo.ErrWrongNumArguments
+			}
+
+			ctx := lookupErrorContext(args[0])
+			if ctx == nil {
+				return tengo.UndefinedValue, nil
+			}
+
+			return errorContextObject(ctx, errorValue(args[0])), nil
+		},
 	},
+	// ldt.is_error(x any) => bool
+	"is_error": &tengo.UserFunction{Name: "is_error", Value: isError},
+	// ldt.is_not_exist(err error) => bool
+	"is_not_exist": isKind(primitive.ErrorKindNotExist),
+	// ldt.is_permission(err error) => bool
+	"is_permission": isKind(primitive.ErrorKindPermission),
+	// ldt.is_exist(err error) => bool
+	"is_exist": isKind(primitive.ErrorKindExist),
+	// ldt.is_timeout(err error) => bool
+	"is_timeout": isKind(primitive.ErrorKindTimeout),
+	// ldt.load_direnv(filename string) => error
+	// => alias of os.load_direnv, the environment is restored on exit if it is not unloaded.
+	"load_direnv": bound("os", "load_direnv"),
+	// ldt.unload_direnv(filename string) => error
+	// => alias of os.unload_direnv.
+	"unload_direnv": bound("os", "unload_direnv"),
+}
+
+// newCatcher returns a Catcher over the given values, only the errors are taken into account.
+func newCatcher(args []tengo.Object) *tengo.ImmutableMap {
+	// filter returns a Catcher over the errors of the given kinds (or the other ones).
+	filter := func(keep bool) tengo.CallableFunc {
+		return func(kinds ...tengo.Object) (tengo.Object, error) {
+			names, err := StringArray(kinds, "kinds")
+			if err != nil {
+				return nil, err
+			}
+
+			var filtered []tengo.Object
+			for _, arg := range args {
+				ctx := lookupErrorContext(arg)
+				if ctx == nil {
+					continue
+				}
+
+				if slices.Contains(names, ctx.Kind) == keep {
+					filtered = append(filtered, arg)
+				}
+			}
+
+			return newCatcher(filtered), nil
+		}
+	}
+
+	var methods *tengo.ImmutableMap
+	methods = &tengo.ImmutableMap{
+		Value: map[string]tengo.Object{
+			// output() => Catcher
+			"output": &tengo.UserFunction{
+				Name: "output",
+				Value: func(nargs ...tengo.Object) (tengo.Object, error) {
+					if len(nargs) != 0 {
+						return nil, tengo.ErrWrongNumArguments
+					}
+
+					for _, arg := range args {
+						fmt.Println(arg)
+					}
+
+					return methods, nil
+				},
+			},
+			// halt() => undefined
+			"halt": &tengo.UserFunction{
+				Name: "halt",
+				Value: func(nargs ...tengo.Object) (tengo.Object, error) {
+					if len(nargs) != 0 {
+						return nil, tengo.ErrWrongNumArguments
+					}
+
+					for _, arg := range args {
+						if errorValue(arg) != nil {
+							return nil, primitive.Halt(arg.String())
+						}
+					}
+
+					return tengo.UndefinedValue, nil
+				},
+			},
+			// first() => error/undefined
+			"first": &tengo.UserFunction{
+				Name: "first",
+				Value: func(nargs ...tengo.Object) (tengo.Object, error) {
+					if len(nargs) != 0 {
+						return nil, tengo.ErrWrongNumArguments
+					}
+
+					for _, arg := range args {
+						if errorValue(arg) != nil {
+							return arg, nil
+						}
+					}
+
+					return tengo.UndefinedValue, nil
+				},
+			},
+			// only(...kind string) => Catcher
+			// => e.g. ldt.catch(err).only("not_exist").halt()
+			"only": &tengo.UserFunction{
+				Name:  "only",
+				Value: filter(true),
+			},
+			// except(...kind string) => Catcher
+			// => e.g. ldt.catch(err).except("exists").halt()
+			"except": &tengo.UserFunction{
+				Name:  "except",
+				Value: filter(false),
+			},
+		},
+	}
+
+	return methods
 }
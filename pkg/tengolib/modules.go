@@ -5,12 +5,17 @@ import (
 )
 
 // BuiltinModules are builtin type standard library modules.
+// The functions shared with lualib are declared in the binding package.
 var BuiltinModules = map[string]map[string]tengo.Object{
-	"filepath": filepathModule,
-	"http":     httpModule,
+	"filepath": withBindings(filepathModule, "filepath"),
+	"http":     withBindings(nil, "http"),
 	"ldt":      ldtModule,
-	"os":       osModule,    // Missing functions from github.com/d5/tengo/v2/stdlib
-	"times":    timesModule, // Missing functions from github.com/d5/tengo/v2/stdlib
+	"log":      withBindings(nil, "log"),
+	"os":       withBindings(osModule, "os"), // Missing functions from github.com/d5/tengo/v2/stdlib
+	"prompt":   withBindings(nil, "prompt"),
+	"strings":  withBindings(nil, "strings"),
+	"sudo":     withBindings(nil, "sudo"),
+	"times":    withBindings(nil, "times"), // Missing functions from github.com/d5/tengo/v2/stdlib
 	"yaml":     yamlModule,
 }
 
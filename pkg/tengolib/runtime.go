@@ -0,0 +1,111 @@
+package tengolib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/parser"
+	"github.com/mdouchement/ldt/pkg/primitive"
+)
+
+// CallSymbol is the global receiving the result of Call.
+// It is the first symbol defined by NewSymbolTable, so its index is the same in all the scripts, even precompiled.
+const CallSymbol = "__ldt_call__"
+
+// callSlot is the index of CallSymbol in the globals.
+var callSlot = func() int {
+	symbol, _, _ := NewSymbolTable().Resolve(CallSymbol, false)
+	return symbol.Index
+}()
+
+// NewSymbolTable returns the symbol table the scripts must be compiled with, it defines the globals used by ldt.
+func NewSymbolTable() *tengo.SymbolTable {
+	symbols := tengo.NewSymbolTable()
+	symbols.Define(CallSymbol)
+	return symbols
+}
+
+var (
+	rtBytecode *tengo.Bytecode
+	rtGlobals  []tengo.Object
+)
+
+// SetRuntime registers the bytecode and the globals of the running script.
+// They are needed by Call to run the script's functions.
+func SetRuntime(bytecode *tengo.Bytecode, globals []tengo.Object) {
+	rtBytecode = bytecode
+	rtGlobals = globals
+}
+
+// Call calls the given callable object with the given arguments.
+// Compiled functions are run on a dedicated VM sharing the constants and the globals of the running script.
+func Call(fn tengo.Object, args ...tengo.Object) (tengo.Object, error) {
+	if !fn.CanCall() {
+		return nil, fmt.Errorf("%s is not callable", fn.TypeName())
+	}
+
+	if _, ok := fn.(*tengo.CompiledFunction); !ok {
+		return fn.Call(args...)
+	}
+
+	if rtBytecode == nil {
+		return nil, errors.New("no running script")
+	}
+
+	// The function and its arguments are appended to the script's constants
+	// because the function's instructions refer to them by index.
+	base := len(rtBytecode.Constants)
+	constants := make([]tengo.Object, 0, base+1+len(args))
+	constants = append(constants, rtBytecode.Constants...)
+	constants = append(constants, fn)
+	constants = append(constants, args...)
+
+	var insts []byte
+	for i := base; i < len(constants); i++ {
+		insts = append(insts, tengo.MakeInstruction(parser.OpConstant, i)...)
+	}
+	insts = append(insts, tengo.MakeInstruction(parser.OpCall, len(args), 0)...)
+	insts = append(insts, tengo.MakeInstruction(parser.OpSetGlobal, callSlot)...)
+	insts = append(insts, tengo.MakeInstruction(parser.OpSuspend)...)
+
+	bytecode := &tengo.Bytecode{
+		FileSet:      rtBytecode.FileSet,
+		MainFunction: &tengo.CompiledFunction{Instructions: insts},
+		Constants:    constants,
+	}
+
+	previous := rtGlobals[callSlot]
+	defer func() {
+		rtGlobals[callSlot] = previous
+	}()
+
+	vm := tengo.NewVM(bytecode, rtGlobals, -1)
+	if !primitive.IsExiting() {
+		stop := context.AfterFunc(primitive.Context(), vm.Abort)
+		defer stop()
+	}
+
+	if err := vm.Run(); err != nil {
+		return nil, err
+	}
+	if err := primitive.Interrupted(); err != nil && !primitive.IsExiting() {
+		return nil, err
+	}
+
+	return rtGlobals[callSlot], nil
+}
+
+// callableArg returns the callable argument at the given position.
+func callableArg(args []tengo.Object, i int, name string) (tengo.Object, error) {
+	if !args[i].CanCall() {
+		return nil, tengo.ErrInvalidArgumentType{
+			Name:     name,
+			Expected: "callable",
+			Found:    args[i].TypeName(),
+		}
+	}
+
+	return args[i], nil
+}